@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// dbNameCategories holds the taxonomy document under taxonomyKey. Editing the
+// document adds categories to the game without any code change.
+const dbNameCategories = "categories"
+
+const taxonomyKey = "taxonomy"
+
+// loadTaxonomy replaces the default taxonomy with the configured one, and
+// stores the default one when none is configured yet. It then calls loaded on
+// the UI goroutine.
+func (p *pubsub) loadTaxonomy(ctx app.Context, loaded func(ctx app.Context)) {
+	ctx.Async(func() {
+		doc, err := p.ledger.Get(dbNameCategories, taxonomyKey)
+		if errors.Is(err, ErrNotFound) {
+			doc, err = json.Marshal(p.taxonomy)
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = p.ledger.Put(dbNameCategories, taxonomyKey, doc)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ctx.Dispatch(loaded)
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		t, err := economy.ParseTaxonomy(doc)
+		if err != nil {
+			// keep playing with the default categories
+			log.Println("Ignoring invalid taxonomy: " + err.Error())
+			ctx.Dispatch(loaded)
+			return
+		}
+		ctx.Dispatch(func(ctx app.Context) {
+			p.taxonomy = t
+			p.market.SetTaxonomy(t)
+			p.matches = p.bestMatches()
+			p.filteredRequests = p.market.Category(p.category)
+			// categories adopted by the community are not in the document
+			p.adoptCategories()
+			loaded(ctx)
+		})
+	})
+}
+
+// categoryButtons returns the categories of the chart buttons: the top level
+// categories and the subcategories of the selected one, in the reverse order
+// of the right floated buttons.
+func (p *pubsub) categoryButtons() []economy.Category {
+	res := []economy.Category{}
+	for _, c := range p.taxonomy.All() {
+		if c.Parent == "" || p.taxonomy.Contains(c.Parent, p.category) {
+			res = append([]economy.Category{c}, res...)
+		}
+	}
+	return res
+}
+
+// categoryOptions returns the options of a category select, subcategories
+// indented under their parent, after a placeholder option without value.
+func (p *pubsub) categoryOptions(placeholder string) []app.UI {
+	opts := []app.UI{app.Option().Selected(true).Value("").Text(placeholder)}
+	for _, c := range p.taxonomy.All() {
+		opts = append(opts, app.Option().Value(c.ID).Text(strings.Repeat("- ", p.taxonomy.Depth(c.ID))+c.Name))
+	}
+	return opts
+}
+
+// categoryBadge renders the category of a demand or offer.
+func (p *pubsub) categoryBadge(category string) app.UI {
+	return app.Span().Class("badge rounded-pill bg-info text-dark").Body(
+		app.If(p.taxonomy.Icon(category) != "", func() app.UI {
+			return app.I().Class("fa-solid " + p.taxonomy.Icon(category) + " me-1")
+		}),
+		app.Text(strings.ToUpper(p.taxonomy.Name(category))),
+	)
+}
+
+// unitPlaceholder hints at the default unit of the selected category.
+func (p *pubsub) unitPlaceholder(category string) string {
+	if u := p.taxonomy.Unit(category); u != "" {
+		return "Unit (" + u + ")"
+	}
+	return "Unit (litres, kg, ...)"
+}
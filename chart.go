@@ -0,0 +1,529 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// svgNS is the namespace of the SVG elements, go-app creates elements in the
+// HTML namespace otherwise.
+const svgNS = "http://www.w3.org/2000/svg"
+
+// Geometry of the charts in viewBox units. The SVG scales to the width of its
+// container, so these only set the proportions.
+const (
+	chartWidth  = 800
+	chartHeight = 320
+	chartLeft   = 44
+	chartRight  = 12
+	chartTop    = 12
+	chartBottom = 36
+	// chartLabels is the most labels on the time axis.
+	chartLabels = 8
+)
+
+func svg(tag string) app.HTMLElem {
+	return app.Elem(tag).XMLNS(svgNS)
+}
+
+// chartColors are the colours of the series of a chart, the first one when
+// there is a single series.
+var chartColors = []string{"#2187e7", "#f0ad4e", "#5cb85c", "#d9534f", "#a07cf0", "#5bc0de", "#e83e8c", "#c0c0c0"}
+
+// chartSeries is a named line of a chart.
+type chartSeries struct {
+	ID      string
+	Name    string
+	Color   string
+	Hidden  bool
+	Buckets []economy.Bucket
+}
+
+// ratioChart renders the supply/demand ratio of series of contiguous buckets
+// as scalable SVG lines with axes and a tooltip per bucket. The series share
+// the time axis of the first one. Buckets without demand leave a gap.
+type ratioChart struct {
+	app.Compo
+	Series []chartSeries
+}
+
+// buckets returns the buckets of the time axis.
+func (c *ratioChart) buckets() []economy.Bucket {
+	if len(c.Series) == 0 {
+		return nil
+	}
+	return c.Series[0].Buckets
+}
+
+// chartX returns the middle of the bucket i of n.
+func chartX(i, n int) float64 {
+	w := float64(chartWidth - chartLeft - chartRight)
+	return chartLeft + w*(float64(i)+0.5)/float64(n)
+}
+
+func (c *ratioChart) x(i int) float64 {
+	return chartX(i, len(c.buckets()))
+}
+
+func (c *ratioChart) y(ratio float64) float64 {
+	h := float64(chartHeight - chartTop - chartBottom)
+	return chartTop + h*(1-ratio)
+}
+
+func (c *ratioChart) Render() app.UI {
+	return svg("svg").Class("chart").Attr("viewBox", fmt.Sprintf("0 0 %d %d", chartWidth, chartHeight)).Attr("role", "img").Aria("label", "Supply/Demand Ratio").Body(
+		c.yAxis(),
+		timeAxis(c.buckets()),
+		app.Range(c.Series).Slice(func(i int) app.UI {
+			s := c.Series[i]
+			if s.Hidden {
+				return nil
+			}
+			return svg("g").Body(
+				svg("path").Class("chart-line").Attr("stroke", s.Color).Attr("d", c.path(s.Buckets)),
+				app.Range(s.Buckets).Slice(func(j int) app.UI {
+					b := s.Buckets[j]
+					if b.Demands == 0 {
+						return nil
+					}
+					return svg("circle").Class("chart-point").Attr("stroke", s.Color).Attr("cx", c.x(j)).Attr("cy", c.y(b.Ratio)).Attr("r", 5).Body(
+						svg("title").Text(c.tooltip(s, b)),
+					)
+				}),
+			)
+		}),
+	)
+}
+
+// path joins the buckets with demands, breaking at the empty ones.
+func (c *ratioChart) path(buckets []economy.Bucket) string {
+	var b strings.Builder
+	move := true
+	for i, bk := range buckets {
+		if bk.Demands == 0 {
+			move = true
+			continue
+		}
+		cmd := "L"
+		if move {
+			cmd = "M"
+		}
+		fmt.Fprintf(&b, "%s%.1f %.1f ", cmd, c.x(i), c.y(bk.Ratio))
+		move = false
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (c *ratioChart) yAxis() app.UI {
+	ticks := []float64{0, 0.2, 0.4, 0.6, 0.8, 1}
+	return svg("g").Class("chart-axis").Body(
+		app.Range(ticks).Slice(func(i int) app.UI {
+			y := c.y(ticks[i])
+			return svg("g").Body(
+				svg("line").Class("chart-grid").Attr("x1", chartLeft).Attr("x2", chartWidth-chartRight).Attr("y1", y).Attr("y2", y),
+				svg("text").Attr("x", chartLeft-6).Attr("y", y+4).Attr("text-anchor", "end").Text(strconv.FormatFloat(ticks[i], 'f', 1, 64)),
+			)
+		}),
+		svg("line").Attr("x1", chartLeft).Attr("x2", chartLeft).Attr("y1", chartTop).Attr("y2", chartHeight-chartBottom),
+	)
+}
+
+// timeAxis renders the time axis of contiguous buckets.
+func timeAxis(buckets []economy.Bucket) app.UI {
+	every := (len(buckets) + chartLabels - 1) / chartLabels
+	return svg("g").Class("chart-axis").Body(
+		svg("line").Attr("x1", chartLeft).Attr("x2", chartWidth-chartRight).Attr("y1", chartHeight-chartBottom).Attr("y2", chartHeight-chartBottom),
+		app.Range(buckets).Slice(func(i int) app.UI {
+			if (len(buckets)-1-i)%every != 0 {
+				return nil
+			}
+			return svg("text").Attr("x", chartX(i, len(buckets))).Attr("y", chartHeight-chartBottom+20).Attr("text-anchor", "middle").Text(bucketLabel(buckets, buckets[i]))
+		}),
+	)
+}
+
+// timeLabel formats the start of a bucket lasting span, with its day when
+// the chart covers several days.
+func timeLabel(t time.Time, span time.Duration, days bool) string {
+	switch {
+	case span >= 360*24*time.Hour:
+		return t.Format("2006")
+	case span >= 28*24*time.Hour:
+		return t.Format("Jan 2006")
+	case span >= 23*time.Hour:
+		return t.Format("2 Jan")
+	case days:
+		return t.Format("2 Jan 15:04")
+	}
+	return t.Format("15:04")
+}
+
+// bucketLabel formats the start of a bucket of contiguous buckets.
+func bucketLabel(buckets []economy.Bucket, b economy.Bucket) string {
+	first, last := buckets[0].Start, buckets[len(buckets)-1].End.Add(-time.Nanosecond)
+	days := first.YearDay() != last.YearDay() || first.Year() != last.Year()
+	return timeLabel(b.Start, b.End.Sub(b.Start), days)
+}
+
+// tooltip describes a bucket of a series.
+func (c *ratioChart) tooltip(s chartSeries, b economy.Bucket) string {
+	return s.Name + ", " + bucketLabel(c.buckets(), b) + ": " + count(b.Demands, "demand", "demands") + ", " + count(b.Supplies, "supply", "supplies") + ", ratio " + strconv.FormatFloat(b.Ratio, 'f', 2, 64)
+}
+
+func count(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(n) + " " + many
+}
+
+// volumeChart renders the quantities demanded, supplied and still missing of
+// contiguous buckets as SVG bars sharing the time axis of ratioChart: the
+// demanded bar next to the supplied one with the backlog stacked on top.
+type volumeChart struct {
+	app.Compo
+	Unit    string
+	Skipped int
+	Buckets []economy.Bucket
+	Volumes []economy.Volume
+}
+
+// scale returns the top of the value axis, 1, 2 or 5 times a power of 10 above
+// the highest bar.
+func (c *volumeChart) scale() float64 {
+	highest := 0.0
+	for _, v := range c.Volumes {
+		highest = math.Max(highest, math.Max(v.Demanded, v.Supplied+v.Backlog))
+	}
+	if highest <= 0 {
+		return 1
+	}
+	pow := math.Pow(10, math.Floor(math.Log10(highest)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*pow >= highest {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+func (c *volumeChart) y(amount, scale float64) float64 {
+	h := float64(chartHeight - chartTop - chartBottom)
+	return chartTop + h*(1-amount/scale)
+}
+
+func (c *volumeChart) Render() app.UI {
+	scale := c.scale()
+	// an empty chart without buckets, two bars in 60% of a bucket otherwise
+	w := 0.0
+	if len(c.Buckets) > 0 {
+		w = float64(chartWidth-chartLeft-chartRight) / float64(len(c.Buckets)) * 0.3
+	}
+	return app.Div().Body(
+		svg("svg").Class("chart").Attr("viewBox", fmt.Sprintf("0 0 %d %d", chartWidth, chartHeight)).Attr("role", "img").Aria("label", "Volume").Body(
+			c.yAxis(scale),
+			timeAxis(c.Buckets),
+			app.Range(c.Volumes).Slice(func(i int) app.UI {
+				v := c.Volumes[i]
+				if v.Demanded+v.Supplied+v.Backlog == 0 {
+					return nil
+				}
+				x := chartX(i, len(c.Buckets))
+				// demanded on the left, supplied and the backlog on top of it on
+				// the right
+				bars := []app.UI{svg("title").Text(c.tooltip(c.Buckets[i], v))}
+				for _, bar := range []struct {
+					class  string
+					x      float64
+					base   float64
+					amount float64
+				}{
+					{"chart-bar-demanded", x - w, 0, v.Demanded},
+					{"chart-bar-supplied", x, 0, v.Supplied},
+					{"chart-bar-backlog", x, v.Supplied, v.Backlog},
+				} {
+					if bar.amount <= 0 {
+						continue
+					}
+					top := c.y(bar.base+bar.amount, scale)
+					bars = append(bars, svg("rect").Class("chart-bar "+bar.class).Attr("x", bar.x).Attr("y", top).Attr("width", w).Attr("height", c.y(bar.base, scale)-top))
+				}
+				return svg("g").Body(bars...)
+			}),
+		),
+		app.Div().Class("d-flex flex-wrap align-items-center small text-info").Body(
+			app.Span().Class("chart-swatch chart-bar-demanded me-1"),
+			app.Span().Class("me-3").Text("Demanded"),
+			app.Span().Class("chart-swatch chart-bar-supplied me-1"),
+			app.Span().Class("me-3").Text("Supplied"),
+			app.Span().Class("chart-swatch chart-bar-backlog me-1"),
+			app.Span().Class("me-3").Text("Backlog"),
+			app.If(c.Skipped > 0, func() app.UI {
+				return app.Span().Class("text-muted").Text(count(c.Skipped, "demand", "demands") + " in other units than " + c.Unit + " left out")
+			}),
+		),
+	)
+}
+
+func (c *volumeChart) yAxis(scale float64) app.UI {
+	ticks := []float64{0, 0.2, 0.4, 0.6, 0.8, 1}
+	return svg("g").Class("chart-axis").Body(
+		app.Range(ticks).Slice(func(i int) app.UI {
+			y := c.y(ticks[i]*scale, scale)
+			return svg("g").Body(
+				svg("line").Class("chart-grid").Attr("x1", chartLeft).Attr("x2", chartWidth-chartRight).Attr("y1", y).Attr("y2", y),
+				svg("text").Attr("x", chartLeft-6).Attr("y", y+4).Attr("text-anchor", "end").Text(strconv.FormatFloat(ticks[i]*scale, 'g', 4, 64)),
+			)
+		}),
+		svg("line").Attr("x1", chartLeft).Attr("x2", chartLeft).Attr("y1", chartTop).Attr("y2", chartHeight-chartBottom),
+		svg("text").Attr("x", chartLeft-6).Attr("y", chartTop-2).Attr("text-anchor", "end").Text(c.Unit),
+	)
+}
+
+// tooltip describes the volume of a bucket.
+func (c *volumeChart) tooltip(b economy.Bucket, v economy.Volume) string {
+	amount := func(a float64) string {
+		return economy.Quantity{Amount: math.Round(a*100) / 100, Unit: c.Unit}.String()
+	}
+	return bucketLabel(c.Buckets, b) + ": " + amount(v.Demanded) + " demanded, " + amount(v.Supplied) + " supplied, " + amount(v.Backlog) + " backlog"
+}
+
+// chartVolumes returns the volume chart of the filtered requests at now, in
+// the unit of the selected category.
+func (p *pubsub) chartVolumes(now time.Time) *volumeChart {
+	requests := p.chartRequests(p.filteredRequests)
+	buckets := p.chartBuckets(requests, now)
+	volumes, unit, skipped := economy.Volumes(buckets, requests, p.taxonomy.Unit(p.category))
+	return &volumeChart{
+		Unit:    unit,
+		Skipped: skipped,
+		Buckets: buckets,
+		Volumes: volumes,
+	}
+}
+
+// chartRequests returns the demands of ids shown in the charts, the ones of
+// the citizen in the personal stats.
+func (p *pubsub) chartRequests(ids []string) []economy.Request {
+	requests := []economy.Request{}
+	for _, id := range ids {
+		r := p.market.Request(id)
+		if p.stats == "Personal" && !p.mine(r.CitizenID) {
+			continue
+		}
+		requests = append(requests, r)
+	}
+	return requests
+}
+
+// chartBuckets aggregates the requests in the buckets of the period ending
+// with now, or of the custom range, in the local time.
+func (p *pubsub) chartBuckets(requests []economy.Request, now time.Time) []economy.Bucket {
+	var buckets []economy.Bucket
+	var err error
+	if p.period == Custom {
+		buckets, err = economy.RangeBuckets(requests, p.rangeFrom, p.rangeTo, time.Local)
+	} else {
+		buckets, err = economy.Buckets(requests, p.period, now, time.Local)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return buckets
+}
+
+// chartSeries returns the series of the chart at now: the filtered requests,
+// or every top level category when comparing them.
+func (p *pubsub) chartSeries(now time.Time) []chartSeries {
+	if !p.compare {
+		name := p.taxonomy.Name(p.category)
+		if p.category == economy.CategoryAll {
+			name = "All"
+		}
+		return []chartSeries{{
+			ID:      p.category,
+			Name:    name,
+			Color:   chartColors[0],
+			Buckets: p.chartBuckets(p.chartRequests(p.filteredRequests), now),
+		}}
+	}
+	roots := p.taxonomy.Roots()
+	res := make([]chartSeries, 0, len(roots))
+	for i, c := range roots {
+		res = append(res, chartSeries{
+			ID:      c.ID,
+			Name:    c.Name,
+			Color:   chartColors[i%len(chartColors)],
+			Hidden:  p.hiddenSeries[c.ID],
+			Buckets: p.chartBuckets(p.chartRequests(p.market.Category(c.ID)), now),
+		})
+	}
+	return res
+}
+
+// chartLegend renders a toggle per series of the chart.
+func (p *pubsub) chartLegend(series []chartSeries) app.UI {
+	return app.Div().Class("d-flex flex-wrap mb-2").Body(
+		app.Range(series).Slice(func(i int) app.UI {
+			s := series[i]
+			class := "btn btn-sm btn-outline-info chart-legend me-1 mb-1"
+			if s.Hidden {
+				class += " hidden"
+			}
+			return app.Button().Class(class).Value(s.ID).Title("Show or hide "+s.Name).Body(
+				app.Span().Class("chart-swatch me-1").Style("background-color", s.Color),
+				app.Text(s.Name),
+			).OnClick(p.onToggleSeries)
+		}),
+	)
+}
+
+func (p *pubsub) onCompare(ctx app.Context, e app.Event) {
+	p.compare = ctx.JSSrc().Get("checked").Bool()
+}
+
+func (p *pubsub) onToggleSeries(ctx app.Context, e app.Event) {
+	id := ctx.JSSrc().Get("value").String()
+	p.hiddenSeries[id] = !p.hiddenSeries[id]
+}
+
+// Bounds of the zoom of the chart.
+const (
+	minChartSpan = 10 * time.Minute
+	maxChartSpan = 10 * 365 * 24 * time.Hour
+)
+
+// rangeLayout is the layout of the custom range inputs.
+const rangeLayout = "2006-01-02T15:04"
+
+// chartRange returns the range covered by the chart.
+func (p *pubsub) chartRange(now time.Time) (time.Time, time.Time) {
+	if p.period == Custom {
+		return p.rangeFrom, p.rangeTo
+	}
+	buckets, err := economy.EmptyBuckets(p.period, now, time.Local)
+	if err != nil {
+		return now.Add(-time.Hour), now
+	}
+	return buckets[0].Start, buckets[len(buckets)-1].End
+}
+
+// setRange shows a custom range on the chart.
+func (p *pubsub) setRange(from, to time.Time) {
+	if span := to.Sub(from); span < minChartSpan {
+		from = from.Add(span/2 - minChartSpan/2)
+		to = from.Add(minChartSpan)
+	} else if span > maxChartSpan {
+		from = from.Add(span/2 - maxChartSpan/2)
+		to = from.Add(maxChartSpan)
+	}
+	p.period = Custom
+	p.rangeFrom, p.rangeTo = from, to
+	deactivate(".period.active")
+}
+
+// zoom scales the range of the chart around its middle.
+func (p *pubsub) zoom(factor float64) {
+	from, to := p.chartRange(time.Now())
+	span := time.Duration(float64(to.Sub(from)) * factor)
+	middle := from.Add(to.Sub(from) / 2)
+	p.setRange(middle.Add(-span/2), middle.Add(span/2))
+}
+
+// pan moves the range of the chart by a fraction of its span.
+func (p *pubsub) pan(fraction float64) {
+	from, to := p.chartRange(time.Now())
+	shift := time.Duration(float64(to.Sub(from)) * fraction)
+	p.setRange(from.Add(shift), to.Add(shift))
+}
+
+func (p *pubsub) onZoomIn(ctx app.Context, e app.Event) {
+	p.zoom(0.5)
+}
+
+func (p *pubsub) onZoomOut(ctx app.Context, e app.Event) {
+	p.zoom(2)
+}
+
+func (p *pubsub) onPanBack(ctx app.Context, e app.Event) {
+	p.pan(-0.5)
+}
+
+func (p *pubsub) onPanForward(ctx app.Context, e app.Event) {
+	p.pan(0.5)
+}
+
+// onChartWheel zooms with the mouse wheel, or pans when it scrolls sideways.
+func (p *pubsub) onChartWheel(ctx app.Context, e app.Event) {
+	e.PreventDefault()
+	dx, dy := e.Get("deltaX").Float(), e.Get("deltaY").Float()
+	switch {
+	case dx > 0 && dx > dy && dx > -dy:
+		p.pan(0.1)
+	case dx < 0 && -dx > dy && -dx > -dy:
+		p.pan(-0.1)
+	case dy > 0:
+		p.zoom(1.25)
+	case dy < 0:
+		p.zoom(0.8)
+	}
+}
+
+// onRange sets the start or the end of the custom range from its input.
+func (p *pubsub) onRange(ctx app.Context, e app.Event) {
+	t, err := time.ParseInLocation(rangeLayout, ctx.JSSrc().Get("value").String(), time.Local)
+	if err != nil {
+		return
+	}
+	from, to := p.chartRange(time.Now())
+	if ctx.JSSrc().Get("name").String() == "range-from" {
+		from = t
+	} else {
+		to = t
+	}
+	if !to.After(from) {
+		p.createNotification(ctx, NotificationWarning, "Invalid range!", "The end of the range must be after its start.")
+		return
+	}
+	p.setRange(from, to)
+}
+
+// chartControls renders the range inputs and the zoom and pan buttons of the
+// chart.
+func (p *pubsub) chartControls(now time.Time) app.UI {
+	from, to := p.chartRange(now)
+	return app.Div().Class("d-flex flex-wrap align-items-center mb-2").Body(
+		app.Div().Class("input-group input-group-sm w-auto me-2").Body(
+			app.Span().Class("input-group-text").Text("From"),
+			app.Input().Class("form-control").Type("datetime-local").Name("range-from").Value(from.In(time.Local).Format(rangeLayout)).Aria("label", "Start of the range").OnChange(p.onRange),
+			app.Span().Class("input-group-text").Text("To"),
+			app.Input().Class("form-control").Type("datetime-local").Name("range-to").Value(to.In(time.Local).Format(rangeLayout)).Aria("label", "End of the range").OnChange(p.onRange),
+		),
+		app.Div().Class("btn-group btn-group-sm").Body(
+			app.Button().Class("btn btn-outline-info").Title("Earlier").Body(app.I().Class("fa-solid fa-chevron-left")).OnClick(p.onPanBack),
+			app.Button().Class("btn btn-outline-info").Title("Zoom out").Body(app.I().Class("fa-solid fa-magnifying-glass-minus")).OnClick(p.onZoomOut),
+			app.Button().Class("btn btn-outline-info").Title("Zoom in").Body(app.I().Class("fa-solid fa-magnifying-glass-plus")).OnClick(p.onZoomIn),
+			app.Button().Class("btn btn-outline-info").Title("Later").Body(app.I().Class("fa-solid fa-chevron-right")).OnClick(p.onPanForward),
+		),
+		app.Div().Class("form-check form-switch ms-3").Body(
+			app.Input().ID("compare").Class("form-check-input").Type("checkbox").Checked(p.compare).OnChange(p.onCompare),
+			app.Label().Class("form-check-label").For("compare").Text("Compare categories"),
+		),
+	)
+}
+
+// deactivate removes the active class of the element matching selector, if
+// any.
+func deactivate(selector string) {
+	el := app.Window().Get("document").Call("querySelector", selector)
+	if el.Truthy() {
+		el.Get("classList").Call("remove", "active")
+	}
+}
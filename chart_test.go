@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+func TestVolumeChartScale(t *testing.T) {
+	tests := []struct {
+		name    string
+		volumes []economy.Volume
+		want    float64
+	}{
+		{"empty", nil, 1},
+		// the demand is not stacked on the supplies
+		{"demanded", []economy.Volume{{Demanded: 10, Supplied: 4, Backlog: 6}}, 10},
+		{"supplied and backlog", []economy.Volume{{Demanded: 4, Supplied: 4, Backlog: 8}}, 20},
+		{"highest bucket", []economy.Volume{{Demanded: 3}, {Demanded: 40, Supplied: 1}}, 50},
+	}
+	for _, tt := range tests {
+		c := &volumeChart{Volumes: tt.volumes}
+		if got := c.scale(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// Supplying a demand is a claim/confirm handshake:
+//
+//  1. a supplier publishes a supplyClaim of an amount against the version of
+//     the demand it has seen
+//  2. the requester, who is the only writer of its demand records, records
+//     the claims in the order it receives them as contributions capped by the
+//     remaining quantity, bumps the version and publishes the updated record
+//     as a confirm
+//  3. every claim on a fulfilled demand is published back as a reject,
+//     a claimRejection signed by the requester
+//
+// Pubsub does not replay messages: claims on a demand whose requester is
+// offline are lost and the supplier has to claim again once it is back.
+
+// errVersionConflict is returned by compareAndPut when the stored record has
+// moved past the expected version.
+var errVersionConflict = errors.New("demand record version conflict")
+
+type supplyClaim struct {
+	RequestID string
+	// Version is the version of the demand the claim was made against.
+	Version  int
+	Supplier string
+	Amount   float64
+	// OfferID is set when the claim is made by the matcher of an economy.Offer.
+	OfferID   string `json:",omitempty"`
+	ClaimedAt time.Time
+	// Signer and Signature are the consent of the supplier, see signing.go.
+	Signer    []byte
+	Signature []byte
+}
+
+// claimRejection is the refusal of a claim by the requester of its demand.
+// Signer and Signature are the requester's, see signing.go.
+type claimRejection struct {
+	Claim      supplyClaim
+	Requester  string
+	RejectedAt time.Time
+	Signer     []byte
+	Signature  []byte
+}
+
+// arbitrateClaim accepts or rejects a claim on one of our own demands.
+func (p *pubsub) arbitrateClaim(ctx app.Context, c supplyClaim) {
+	d := p.market.Request(c.RequestID)
+	if !p.market.Has(c.RequestID) || !p.mine(d.CitizenID) {
+		// not ours to decide
+		return
+	}
+
+	if d.Fulfilled || c.Version > d.Version {
+		p.rejectClaim(ctx, c)
+		return
+	}
+
+	// claims made against an older version are still valid as long as
+	// something remains to be supplied, they are capped to what remains
+	accepted := d.Contribute(c.contribution())
+	if accepted == 0 {
+		p.rejectClaim(ctx, c)
+		return
+	}
+	expected := d.Version
+	d.Version++
+	if err := d.Sign(p.key); err != nil {
+		log.Fatal(err)
+	}
+	// claims are arbitrated one at a time on the UI goroutine so the next
+	// claim on this demand already sees this contribution
+	p.market.Apply(d)
+	p.matches = p.bestMatches()
+
+	// the versions of a demand are stored one at a time in order, so the
+	// next claim never reads the record before this one is stored
+	p.demandWrites.run(d.ID, func() {
+		stored, err := compareAndPut(p.ledger, d, expected)
+		if errors.Is(err, errVersionConflict) {
+			// the contribution was not stored, go back to the stored record
+			ctx.Dispatch(func(ctx app.Context) {
+				if err := p.keys.Verify(stored); err != nil {
+					p.rejected.add(verified(err))
+					return
+				}
+				p.market.Replace(stored)
+				p.matches = p.bestMatches()
+			})
+			p.rejectClaim(ctx, c)
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = publishMessage(p.transport, p.topic, messageConfirm, d)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+func (p *pubsub) rejectClaim(ctx app.Context, c supplyClaim) {
+	r := claimRejection{Claim: c, Requester: p.citizenID, RejectedAt: time.Now()}
+	r.sign(p.key)
+	ctx.Async(func() {
+		err := publishMessage(p.transport, p.topic, messageReject, r)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+// applyRejection tells the supplier of a claim that the requester of the
+// demand turned it down. Rejections of other citizens than the requester are
+// refused, peers could replay any claim seen on the topic.
+func (p *pubsub) applyRejection(ctx app.Context, r claimRejection) {
+	c := r.Claim
+	if !p.mine(c.Supplier) || !p.market.Has(c.RequestID) {
+		return
+	}
+	if err := p.keys.Check(p.market.Request(c.RequestID).CitizenID, r.Signer); err != nil {
+		p.reject(verified(err))
+		return
+	}
+	if c.OfferID != "" {
+		p.releaseReservation(c)
+		return
+	}
+	p.createNotification(ctx, NotificationWarning, "Too late!", "Another citizen has already supplied this demand.")
+}
+
+// writeQueue runs the writes of every key one at a time, in the order they
+// are queued, off the UI goroutine.
+type writeQueue struct {
+	mu      sync.Mutex
+	pending map[string][]func()
+}
+
+func newWriteQueue() *writeQueue {
+	return &writeQueue{pending: make(map[string][]func())}
+}
+
+// run queues write after the writes of key already queued.
+func (q *writeQueue) run(key string, write func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[key] = append(q.pending[key], write)
+	if len(q.pending[key]) == 1 {
+		go q.drain(key)
+	}
+}
+
+// drain runs the writes of key until none is queued. The running write stays
+// first in the queue so run does not start another drain meanwhile.
+func (q *writeQueue) drain(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending[key]) > 0 {
+		write := q.pending[key][0]
+		q.mu.Unlock()
+		write()
+		q.mu.Lock()
+		q.pending[key] = q.pending[key][1:]
+	}
+	delete(q.pending, key)
+}
+
+// compareAndPut stores d only if the stored record is still at the expected
+// version. On errVersionConflict it returns the stored record.
+func compareAndPut(l Ledger, d economy.Request, expected int) (economy.Request, error) {
+	stored := economy.Request{}
+	v, err := l.Get(dbNameSupplyDemand, d.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return stored, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(v, &stored); err != nil {
+			return stored, err
+		}
+		if stored.Version != expected {
+			return stored, errVersionConflict
+		}
+	}
+
+	record, err := json.Marshal(d)
+	if err != nil {
+		return stored, err
+	}
+	return stored, l.Put(dbNameSupplyDemand, d.ID, record)
+}
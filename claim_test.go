@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+func TestCompareAndPut(t *testing.T) {
+	l := newMemoryLedger()
+	d := economy.Request{ID: "01HQ", Quantity: economy.Quantity{Amount: 10, Unit: "kg"}}
+	if _, err := compareAndPut(l, d, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Version = 1
+	if _, err := compareAndPut(l, d, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second writer arbitrating against the same version loses
+	lost := d
+	lost.Version = 1
+	lost.Contributions = []economy.Contribution{{Supplier: "bob", Amount: 5}}
+	stored, err := compareAndPut(l, lost, 0)
+	if !errors.Is(err, errVersionConflict) {
+		t.Fatalf("got %v, want errVersionConflict", err)
+	}
+	if stored.Version != 1 || len(stored.Contributions) != 0 {
+		t.Fatalf("got stored record %+v", stored)
+	}
+
+	// the local ledger goes back to the stored record
+	m := economy.NewLedger()
+	m.Apply(lost)
+	m.Replace(stored)
+	if got := m.Request(d.ID); len(got.Contributions) != 0 {
+		t.Fatalf("contribution kept after replace: %+v", got)
+	}
+}
+
+// slowLedger delays every read so concurrent writers interleave.
+type slowLedger struct {
+	Ledger
+}
+
+func (l slowLedger) Get(store, key string) ([]byte, error) {
+	time.Sleep(10 * time.Millisecond)
+	return l.Ledger.Get(store, key)
+}
+
+func TestConcurrentClaims(t *testing.T) {
+	l := slowLedger{newMemoryLedger()}
+	d := economy.Request{ID: "01HQ", Quantity: economy.Quantity{Amount: 10, Unit: "kg"}}
+	if _, err := compareAndPut(l, d, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// two claims accepted one after the other on the UI goroutine
+	first := d
+	first.Contribute(economy.Contribution{Supplier: "bob", Amount: 4})
+	first.Version = 1
+	second := first
+	second.Contribute(economy.Contribution{Supplier: "carol", Amount: 6})
+	second.Version = 2
+
+	q := newWriteQueue()
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, r := range []economy.Request{first, second} {
+		wg.Add(1)
+		q.run(d.ID, func() {
+			defer wg.Done()
+			_, errs[i] = compareAndPut(l, r, r.Version-1)
+		})
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("claim %d: %v", i, err)
+		}
+	}
+
+	v, err := l.Get(dbNameSupplyDemand, d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := economy.Request{}
+	if err := json.Unmarshal(v, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 2 || len(stored.Contributions) != 2 {
+		t.Fatalf("got version %d with %d contributions, want 2 and 2", stored.Version, len(stored.Contributions))
+	}
+}
+
+func TestWriteQueueOrder(t *testing.T) {
+	q := newWriteQueue()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	got := map[string][]int{}
+	for i := 0; i < 50; i++ {
+		for _, key := range []string{"a", "b"} {
+			wg.Add(1)
+			q.run(key, func() {
+				defer wg.Done()
+				mu.Lock()
+				got[key] = append(got[key], i)
+				mu.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+	for key, order := range got {
+		for i, n := range order {
+			if n != i {
+				t.Fatalf("writes of %s ran in the order %v", key, order)
+			}
+		}
+	}
+}
+
+func TestRejection(t *testing.T) {
+	now := time.Now()
+	requester, supplier, peer := generateKey(), generateKey(), generateKey()
+	handle := func(key ed25519.PrivateKey) string { return economy.Handle(key.Public().(ed25519.PublicKey)) }
+
+	d := economy.Request{ID: "01HQ", CitizenID: handle(requester), Category: "water", Quantity: economy.Quantity{Amount: 10, Unit: "litres"}, CreatedAt: now}
+	p := &pubsub{
+		market:       economy.NewLedger(),
+		keys:         economy.NewKeyring(),
+		citizenID:    handle(supplier),
+		reservations: map[string]float64{},
+		rejected:     rejections{},
+	}
+	p.market.Apply(d)
+	c := supplyClaim{RequestID: d.ID, Supplier: handle(supplier), Amount: 5, OfferID: "01HR", ClaimedAt: now}
+	c.sign(supplier)
+
+	// a claim seen on the topic replayed as a reject does not decode
+	data, err := newEnvelope(messageReject, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, economy.DefaultTaxonomy(), p.keys, now); !errors.Is(err, errMalformed) {
+		t.Fatalf("replayed claim: got %v, want errMalformed", err)
+	}
+
+	// a peer rejecting in its own name is not the requester
+	forged := claimRejection{Claim: c, Requester: handle(peer), RejectedAt: now}
+	forged.sign(peer)
+	data, err = newEnvelope(messageReject, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeMessage(data, economy.DefaultTaxonomy(), p.keys, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.reservations[reservationKey(c.OfferID, c.RequestID)] = 5
+	p.applyRejection(app.Context{}, m.Payload.(claimRejection))
+	if len(p.reservations) != 1 || p.rejected.total() != 1 {
+		t.Fatalf("forged rejection released the reservation or was not refused")
+	}
+
+	// a peer rejecting in the name of the requester
+	forged.Requester = d.CitizenID
+	forged.sign(peer)
+	data, err = newEnvelope(messageReject, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, economy.DefaultTaxonomy(), p.keys, now); !errors.Is(err, errUnverified) {
+		t.Fatalf("rejection in the name of the requester: got %v, want errUnverified", err)
+	}
+
+	r := claimRejection{Claim: c, Requester: d.CitizenID, RejectedAt: now}
+	r.sign(requester)
+	data, err = newEnvelope(messageReject, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err = decodeMessage(data, economy.DefaultTaxonomy(), p.keys, now); err != nil {
+		t.Fatal(err)
+	}
+	p.applyRejection(app.Context{}, m.Payload.(claimRejection))
+	if len(p.reservations) != 0 {
+		t.Fatal("rejection of the requester did not release the reservation")
+	}
+}
@@ -1,23 +1,26 @@
 package main
 
 import (
-	"encoding/base64"
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
-	"sort"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
-	"github.com/foolin/mixer"
 	"github.com/maxence-charriere/go-app/v10/pkg/app"
-	shell "github.com/stateless-minds/go-ipfs-api"
+	"github.com/stateless-minds/cyber-stasis/economy"
+	"github.com/stateless-minds/cyber-stasis/simulation"
 )
 
+// ipfsAPI is the address of the API of the local IPFS node.
+const ipfsAPI = "localhost:5001"
+
 const dbNameSupplyDemand = "demand_supply"
 const dbNameCitizenReputation = "citizen_reputation"
 const (
@@ -43,55 +46,60 @@ const (
 // embedding app.Compo into a struct.
 type pubsub struct {
 	app.Compo
-	topic string
-	demandRequest
+	topic         string
+	demandRequest economy.Request
 	sendRequest
-	demandRequests           map[string]demandRequest
-	categories               []string
-	sh                       *shell.Shell
-	sub                      *shell.PubSubSubscription
-	citizenID                string
-	newComer                 bool
-	timeRange                []int
-	timeFormat               []string
-	ratio                    []int
-	index                    []int
-	hasChildren              bool
-	filteredRequests         []int
-	validRequests            []int
-	filteredandValidRequests int
-	filteredWaterRequests    []int
-	filteredFoodRequests     []int
-	filteredHousingRequests  []int
-	filteredOtherRequests    []int
-	lastWaterRequest         int
-	lastFoodRequest          int
-	lastHousingRequest       int
-	lastOtherRequest         int
-	ranks                    []ranking
-	showMessages             bool
-	showRatio                bool
-	showTime                 bool
-	showChart                bool
-	showRanks                bool
-	counterDemand            int
-	counterSupply            int
-	counterSameTime          int
-	counterSameTimeFulfilled int
-	coordinates              []coordinate
-	category                 string
-	period                   string
-	stats                    string
-	multiplyer               int
-	notifications            map[string]notification
-	notificationID           int
-	globalEvent              bool
-	resource                 string
-}
-
-type Shortage struct {
-	Category    string
-	Description string
+	market           *economy.Ledger
+	taxonomy         *economy.Taxonomy
+	ledger           Ledger
+	transport        Transport
+	sub              Subscription
+	offerSub         Subscription
+	offers           map[string]economy.Offer
+	offerIndex       []string
+	offerForm        economy.Offer
+	proposals        map[string]economy.CategoryProposal
+	proposalSub      Subscription
+	proposalForm     economy.Category
+	reservations     map[string]float64
+	demandWrites     *writeQueue
+	capabilities     economy.Capabilities
+	hasCapabilities  bool
+	matches          []economy.Suggestion
+	citizenID        string
+	identity         identity
+	identityExport   string
+	identityImport   string
+	key              ed25519.PrivateKey
+	keys             *economy.Keyring
+	sybil            economy.SybilPolicy
+	suspicious       map[string][]string
+	vouches          map[string]economy.Vouch
+	discounted       bool
+	scoring          economy.Scoring
+	history          []economy.HistoryPoint
+	historyOf        string
+	historyPeriod    string
+	limiter          limiter
+	newComer         bool
+	filteredRequests []string
+	ranks            []economy.Ranking
+	showMessages     bool
+	showChart        bool
+	showRanks        bool
+	counterDemand    int
+	counterSupply    int
+	category         string
+	period           string
+	rangeFrom        time.Time
+	rangeTo          time.Time
+	compare          bool
+	hiddenSeries     map[string]bool
+	stats            string
+	notifications    map[string]notification
+	notificationID   int
+	// rejected counts the malformed and invalid messages of peers
+	rejected rejections
 }
 
 type NotificationStatus string
@@ -103,21 +111,6 @@ type notification struct {
 	message string
 }
 
-type coordinate struct {
-	id       int
-	top      int
-	left     int
-	angle    float64
-	distance float64
-}
-
-type ranking struct {
-	citizenID       string
-	demandRatio     float64 // number of personal demands compared to total
-	supplyRatio     float64 // number of personal supplies compared to total
-	reputationIndex float64 // supplyRatio compared to demandRatio
-}
-
 type citizenReputation struct {
 	ID              string  `json:"_id" validate:"uuid_rfc4122"`
 	Type            string  `json:"type" validate:"uuid_rfc4122"`
@@ -126,41 +119,41 @@ type citizenReputation struct {
 }
 
 type sendRequest struct {
-	ID          int
+	ID          string
 	Category    string
-	Quantity    string
+	Quantity    economy.Quantity
 	Details     string
 	Fulfilled   bool
 	CreatedAt   time.Time
 	FulfilledAt time.Time
 }
 
-type demandRequest struct {
-	ID          int
-	CitizenID   string
-	Category    string
-	Quantity    string
-	Details     string
-	CreatedAt   time.Time
-	Fulfilled   bool
-	FulfilledBy string
-	FulfilledAt time.Time
-}
-
 func (p *pubsub) OnMount(ctx app.Context) {
 	p.topic = topicDemand
-	sh := shell.NewShell("localhost:5001")
-	p.sh = sh
-	myPeer, err := p.sh.ID()
+	u := ctx.Page().URL()
+	// ?transport=relay plays through the relay served by main instead of IPFS
+	relayURL := "ws://" + u.Host + relayPath
+	if u.Scheme == "https" {
+		relayURL = "wss://" + u.Host + relayPath
+	}
+	transport, err := openTransport(u.Query().Get("transport"), ipfsAPI, relayURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.transport = transport
+	// ?ledger=memory runs the dashboard without persisting to orbit-db and
+	// ?ledger=bolt persists to the database file of main
+	ledger, err := openLedger(u.Query().Get("ledger"), ipfsAPI, u.Scheme+"://"+u.Host+ledgerPath)
 	if err != nil {
 		log.Fatal(err)
 	}
+	p.ledger = ledger
 
 	/*** Test sending critical messages from all categories ***/
 	// ctx.Async(func() {
 	// 	category := "housing"
 	// 	desc := "Global shortage of housing"
-	// 	s := Shortage{
+	// 	s := economy.Shortage{
 	// 		Category:    category,
 	// 		Description: desc,
 	// 	}
@@ -168,11 +161,11 @@ func (p *pubsub) OnMount(ctx app.Context) {
 	// 	if err != nil {
 	// 		log.Fatal(err)
 	// 	}
-	// 	p.sh.PubSubPublish(topicCritical, string(shortage))
+	// 	p.transport.Publish(topicCritical, shortage)
 	// 	time.Sleep(2 * time.Second)
 	// 	category = "water"
 	// 	desc = "Global shortage of water"
-	// 	s = Shortage{
+	// 	s = economy.Shortage{
 	// 		Category:    category,
 	// 		Description: desc,
 	// 	}
@@ -180,11 +173,11 @@ func (p *pubsub) OnMount(ctx app.Context) {
 	// 	if err != nil {
 	// 		log.Fatal(err)
 	// 	}
-	// 	p.sh.PubSubPublish(topicCritical, string(shortage))
+	// 	p.transport.Publish(topicCritical, shortage)
 	// 	time.Sleep(2 * time.Second)
 	// 	category = "food"
 	// 	desc = "Global shortage of food"
-	// 	s = Shortage{
+	// 	s = economy.Shortage{
 	// 		Category:    category,
 	// 		Description: desc,
 	// 	}
@@ -192,88 +185,61 @@ func (p *pubsub) OnMount(ctx app.Context) {
 	// 	if err != nil {
 	// 		log.Fatal(err)
 	// 	}
-	// 	p.sh.PubSubPublish(topicCritical, string(shortage))
+	// 	p.transport.Publish(topicCritical, shortage)
 	// })
 
-	citizenID := myPeer.ID[len(myPeer.ID)-8:]
-	// replace password with your own
-	password := "mysecretpassword"
-
-	p.citizenID = mixer.EncodeString(password, citizenID)
+	p.keys = economy.NewKeyring()
+	p.setIdentity(ctx, loadIdentity(ctx))
 
 	p.subscribe(ctx)
-	p.demandRequests = make(map[string]demandRequest)
-	p.FetchAllRequests(ctx, app.Event{})
-	p.setTimeAxis(Hour)
-	// 0 to 1 supply/demand
-	for i := 1; i < 11; i++ {
-		p.ratio = append(p.ratio, i)
-	}
-	// default categories
-	p.categories = append(p.categories, "all", "water", "food", "housing", "other")
-	p.category = "All"
-	p.showRatio = true
-	p.showTime = true
+	p.subscribeOffers(ctx)
+	p.subscribeProposals(ctx)
+	p.market = economy.NewLedger()
+	// default categories until the configured ones are loaded
+	p.taxonomy = economy.DefaultTaxonomy()
+	p.market.SetTaxonomy(p.taxonomy)
+	p.offers = make(map[string]economy.Offer)
+	p.proposals = make(map[string]economy.CategoryProposal)
+	p.reservations = make(map[string]float64)
+	p.demandWrites = newWriteQueue()
+	p.rejected = rejections{}
+	p.sybil = economy.DefaultSybilPolicy()
+	p.vouches = make(map[string]economy.Vouch)
+	p.limiter = limiter{}
+	p.loadRankingMode(ctx)
+	p.loadScoring(ctx)
+	p.historyPeriod = Month
+	// stored records are validated against the configured and adopted
+	// categories
+	p.loadTaxonomy(ctx, func(ctx app.Context) {
+		p.FetchAllProposals(ctx, func(ctx app.Context) {
+			p.FetchAllRequests(ctx, app.Event{})
+			p.FetchAllOffers(ctx)
+		})
+	})
+	p.FetchAllVouches(ctx)
+	p.loadCapabilities(ctx)
+	p.period = Hour
+	p.category = economy.CategoryAll
+	p.hiddenSeries = make(map[string]bool)
 	p.showRanks = false
 	// create welcome notification
 	p.notifications = make(map[string]notification)
 }
 
-func (p *pubsub) setTimeAxis(period string) {
-	p.period = period
-	p.timeRange = []int{}
-	p.timeFormat = []string{}
+// periodSpan returns the time covered by a period of the chart.
+func periodSpan(period string) time.Duration {
 	switch period {
-	case "hour":
-		now := time.Now()
-		// defining duration
-		d := (10 * time.Minute)
-		now = now.Round(d)
-		before := now.Add(time.Hour * -1)
-		for i := 1; i < 7; i++ {
-			p.timeRange = append(p.timeRange, i)
-			before = before.Add(time.Minute * 10)
-			min := strconv.Itoa(before.Minute())
-			if before.Minute() == 0 {
-				min += "0"
-			}
-			p.timeFormat = append(p.timeFormat, strconv.Itoa(before.Hour())+":"+min)
-		}
-		p.multiplyer = 132
-	case "day":
-		for i := 1; i < 25; i++ {
-			p.timeRange = append(p.timeRange, i)
-			p.timeFormat = append(p.timeFormat, strconv.Itoa(i)+":00")
-		}
-		p.multiplyer = 33
-	case "week":
-		now := time.Now()
-		n := 8
-		for i := 1; i < n; i++ {
-			p.timeRange = append(p.timeRange, i)
-			before := now.AddDate(0, 0, i+1-n)
-			p.timeFormat = append(p.timeFormat, strconv.Itoa(before.Day())+". "+time.Now().Month().String())
-		}
-		p.multiplyer = 113
-	case "month":
-		before := time.Now().AddDate(0, 0, -30)
-
-		for i := 1; i < 31; i++ {
-			p.timeRange = append(p.timeRange, i)
-			before = before.AddDate(0, 0, 1)
-			p.timeFormat = append(p.timeFormat, strconv.Itoa(before.Day())+". "+before.Month().String())
-		}
-		p.multiplyer = 26
-	case "year":
-		now := time.Now()
-		before := now.AddDate(-1, 0, 0)
-		for i := 1; i < 13; i++ {
-			p.timeRange = append(p.timeRange, i)
-			before = before.AddDate(0, 1, 0)
-			p.timeFormat = append(p.timeFormat, before.Month().String()+". "+strconv.Itoa(before.Year()))
-		}
-		p.multiplyer = 66
+	case Day:
+		return 24 * time.Hour
+	case Week:
+		return 7 * 24 * time.Hour
+	case Month:
+		return 30 * 24 * time.Hour
+	case Year:
+		return 365 * 24 * time.Hour
 	}
+	return time.Hour
 }
 
 // The Render method is where the component appearance is defined. Here, a
@@ -346,8 +312,8 @@ func (p *pubsub) Render() app.UI {
 										),
 										app.Li().Class("list-group-item d-flex justify-content-between align-items-start").Body(
 											app.Div().Class("ms-2 me-auto").Body(
-												app.Div().Class("fw-bold").Text("Supply can be sent only in response to an existing demand"),
-												app.Text("Send only goods and services you can provide in real life."),
+												app.Div().Class("fw-bold").Text("Supply can be sent in response to an existing demand or offered in advance"),
+												app.Text("Send and offer only goods and services you can provide in real life. Open offers are matched automatically with incoming demands."),
 											),
 										),
 										app.Li().Class("list-group-item d-flex justify-content-between align-items-start").Body(
@@ -543,18 +509,23 @@ func (p *pubsub) Render() app.UI {
 				}),
 				app.If(p.showMessages, func() app.UI { 
 					return app.Div().Class("card-body").Body(
-						app.Range(p.index).Slice(func(i int) app.UI {
-							i = p.index[i]
-							if p.demandRequests[strconv.Itoa(i)].ID > 0 && !p.demandRequests[strconv.Itoa(i)].Fulfilled {
+						app.Range(p.market.IDs()).Slice(func(i int) app.UI {
+							id := p.market.IDs()[i]
+							if p.market.Request(id).ID != "" && !p.market.Request(id).Fulfilled && !p.market.Request(id).Legacy {
 								return app.Div().Class("d-flex flex-row p-3").Body(
 									app.Img().Src("https://img.icons8.com/color/48/000000/circled-user-female-skin-type-7.png").Width(30).Height(30),
 									app.Div().Class("chat ml-3 p-3").Body(
 										app.Span().Class("pe-2").Body(
-											app.Span().Class("badge rounded-pill bg-info text-dark").Text(strings.ToUpper(p.demandRequests[strconv.Itoa(i)].Category)),
-											app.P().Class("card-text pt-3").Text("Quantity: "+p.demandRequests[strconv.Itoa(i)].Quantity),
-											app.P().Class("card-text").Text("Details: "+p.demandRequests[strconv.Itoa(i)].Details),
+											p.categoryBadge(p.market.Request(id).Category),
+											app.P().Class("card-text pt-3").Text("Quantity: "+p.market.Request(id).Quantity.String()),
+											app.P().Class("card-text").Text("Remaining: "+economy.Quantity{Amount: p.market.Request(id).Remaining(), Unit: p.market.Request(id).Quantity.Unit}.String()),
+											app.Div().Class("progress mb-3").Body(
+												app.Div().Class("progress-bar bg-info").Style("width", fmt.Sprintf("%.0f", p.market.Request(id).Fulfilment()*100)+"%"),
+											),
+											app.P().Class("card-text").Text("Details: "+p.market.Request(id).Details),
 											app.Div().Class("row d-flex justify-content-center align-content-center ps-3 pe-3").Body(
-												app.Button().Class("btn btn-outline-primary btn-sm rounded-pill").ID(strconv.Itoa(p.demandRequests[strconv.Itoa(i)].ID)).Body(app.Text("Send Supply")).OnClick(p.sendSupply),
+												app.Input().ID("supply-"+p.market.Request(id).ID).Class("form-control form-control-sm mb-2").Type("number").Placeholder(strconv.FormatFloat(p.market.Request(id).Remaining(), 'f', -1, 64)),
+												app.Button().Class("btn btn-outline-primary btn-sm rounded-pill").ID(p.market.Request(id).ID).Value(p.market.Request(id).ID).Body(app.Text("Send Supply")).OnClick(p.sendSupply),
 											),
 										),
 									),
@@ -566,28 +537,144 @@ func (p *pubsub) Render() app.UI {
 				app.H6().Class("card-title").Text("What do you need today?"),
 				app.Div().Class("form-group").Body(
 					app.Select().Class("form-select").Aria("label", "Demand category").Body(
-						app.Range(p.categories).Slice(func(i int) app.UI {
-							if p.categories[i] == "all" {
-								return app.Option().Selected(true).Value("").Text("Select Category")
-							} else {
-								return app.Option().Value(p.categories[i]).Text(strings.Title(p.categories[i]))
-							}
-						}),
+						p.categoryOptions("Select Category")...,
 					).Required(true).OnClick(p.onSelect),
 					app.Input().ID("quantity").Class("form-control").Name("quantity").Type("number").Placeholder("Quantity").OnKeyUp(p.onInput),
+					app.Input().ID("unit").Class("form-control").Name("unit").Type("text").Placeholder(p.unitPlaceholder(p.demandRequest.Category)).List(unitListID).OnKeyUp(p.onUnit),
+					unitList(),
 					app.Textarea().Class("form-control").Rows(3).Placeholder("Details").OnKeyUp(p.onMessage),
+					app.Button().Class("btn btn-outline-secondary btn-sm mt-2").Body(app.Text("Share My Location")).OnClick(p.onDemandLocation),
+					app.If(p.demandRequest.Location != nil, func() app.UI {
+						return app.Small().Class("ps-2").Text("Location shared")
+					}),
 				),
 				app.Button().Class("btn btn-outline-info mt-2").ID("submitDemand").Body(app.Text("Send Request")).OnClick(p.sendDemand).Disabled(true),
+				app.Details().Class("pt-3").Body(
+					app.Summary().Class("card-title").Text("What can you supply?"),
+					app.Div().Class("form-group").Body(
+						app.Range(p.taxonomy.All()).Slice(func(i int) app.UI {
+							c := p.taxonomy.All()[i]
+							return app.Div().Class("form-check form-check-inline").Body(
+								app.Input().Class("form-check-input").Type("checkbox").Name("capability-category").Value(c.ID).Checked(p.capabilities.Provides(c.ID) && len(p.capabilities.Categories) > 0).OnChange(p.onCapabilitiesInput),
+								app.Label().Class("form-check-label").Text(c.Name),
+							)
+						}),
+						app.Input().Class("form-control").Name("capability-min").Type("number").Placeholder("Minimum quantity").OnKeyUp(p.onCapabilitiesInput),
+						app.Input().Class("form-control").Name("capability-max").Type("number").Placeholder("Maximum quantity").OnKeyUp(p.onCapabilitiesInput),
+						app.Input().Class("form-control").Name("capability-radius").Type("number").Placeholder("Radius (km)").OnKeyUp(p.onCapabilitiesInput),
+						app.Input().Class("form-control").Name("capability-from").Type("date").Aria("label", "Available from").OnChange(p.onCapabilitiesInput),
+						app.Input().Class("form-control").Name("capability-until").Type("date").Aria("label", "Available until").OnChange(p.onCapabilitiesInput),
+						app.Button().Class("btn btn-outline-secondary btn-sm mt-2").Body(app.Text("Use My Location")).OnClick(p.onCapabilitiesLocation),
+					),
+				),
+				app.If(len(p.matches) > 0, func() app.UI {
+					return app.H6().Class("card-title pt-3").Text("Best Matches For You")
+				}),
+				app.Range(p.matches).Slice(func(i int) app.UI {
+					s := p.matches[i]
+					d := p.market.Request(s.RequestID)
+					info := economy.Quantity{Amount: s.Amount, Unit: d.Quantity.Unit}.String()
+					if s.DistanceKm >= 0 {
+						info += fmt.Sprintf(" - %.1f km", s.DistanceKm)
+					}
+					return app.Div().Class("d-flex flex-row align-items-center p-2").Body(
+						p.categoryBadge(d.Category),
+						app.Span().Class("badge rounded-pill bg-primary ms-2").Text(fmt.Sprintf("%.0f%%", s.Score*100)),
+						app.Small().Class("card-text ps-2 pe-2").Text(info),
+						app.Button().Class("btn btn-outline-primary btn-sm rounded-pill").Value(d.ID).Body(app.Text("Send Supply")).OnClick(p.sendSupply),
+					)
+				}),
+				app.H6().Class("card-title pt-3").Text("What can you offer?"),
+				app.Div().Class("form-group").Body(
+					app.Select().Class("form-select").Name("offer-category").Aria("label", "Offer category").Body(
+						p.categoryOptions("Select Category")...,
+					).OnChange(p.onOfferInput),
+					app.Input().Class("form-control").Name("offer-quantity").Type("number").Placeholder("Quantity").OnKeyUp(p.onOfferInput),
+					app.Input().Class("form-control").Name("offer-unit").Type("text").Placeholder(p.unitPlaceholder(p.offerForm.Category)).List(unitListID).OnKeyUp(p.onOfferInput),
+					app.Input().Class("form-control").Name("offer-location").Type("text").Placeholder("Location (e.g. depot X)").OnKeyUp(p.onOfferInput),
+					app.Input().Class("form-control").Name("offer-until").Type("date").Aria("label", "Available until").OnChange(p.onOfferInput),
+				),
+				app.Button().Class("btn btn-outline-info mt-2").ID("submitOffer").Body(app.Text("Publish Offer")).OnClick(p.publishOffer),
+				app.If(len(p.openOffers()) > 0, func() app.UI {
+					return app.H6().Class("card-title pt-3").Text("Open Offers")
+				}),
+				app.Range(p.openOffers()).Slice(func(i int) app.UI {
+					o := p.offers[p.openOffers()[i]]
+					available := "Available: " + economy.Quantity{Amount: o.Remaining(), Unit: o.Quantity.Unit}.String()
+					if o.Location != "" {
+						available += " at " + o.Location
+					}
+					if !o.AvailableUntil.IsZero() {
+						available += " until " + o.AvailableUntil.Format("2 Jan 2006")
+					}
+					return app.Div().Class("d-flex flex-row p-2").Body(
+						p.categoryBadge(o.Category),
+						app.Small().Class("card-text ps-2").Text(available),
+					)
+				}),
+				app.Details().Class("pt-3").Body(
+					app.Summary().Class("card-title").Text("Missing a category?"),
+					app.Div().Class("form-group").Body(
+						app.Input().Class("form-control").Name("proposal-name").Type("text").Placeholder("Name (e.g. Energy)").OnKeyUp(p.onProposalInput),
+						app.Select().Class("form-select").Name("proposal-parent").Aria("label", "Parent category").Body(
+							p.categoryOptions("Top level category")...,
+						).OnChange(p.onProposalInput),
+						app.Input().Class("form-control").Name("proposal-unit").Type("text").Placeholder("Default unit (e.g. kWh)").OnKeyUp(p.onProposalInput),
+					),
+					app.Button().Class("btn btn-outline-info mt-2").ID("submitProposal").Body(app.Text("Propose Category")).OnClick(p.proposeCategory),
+				),
+				app.Details().Class("pt-3").Body(
+					app.Summary().Class("card-title").Text("Your identity"),
+					app.P().Class("card-text").Body(
+						app.Small().Text("You play as "),
+						app.Code().Text(p.citizenID),
+					),
+					app.Button().Class("btn btn-outline-secondary btn-sm").Body(app.Text("Export")).OnClick(p.exportIdentity),
+					app.Button().Class("btn btn-outline-secondary btn-sm ms-2").Body(app.Text("Rotate Key")).OnClick(p.rotateIdentity),
+					app.If(p.identityExport != "", func() app.UI {
+						return app.Div().Class("pt-2").Body(
+							app.Small().Class("text-muted").Text("Keep this text secret, it lets anyone play as you."),
+							app.Textarea().Class("form-control").Rows(3).ReadOnly(true).Text(p.identityExport),
+						)
+					}),
+					app.Div().Class("form-group pt-2").Body(
+						app.Textarea().Class("form-control").Rows(2).Placeholder("Paste an exported identity").OnInput(p.onIdentityInput),
+					),
+					app.Button().Class("btn btn-outline-info btn-sm mt-2").Body(app.Text("Import")).OnClick(p.importIdentity),
+				),
+				app.If(len(p.pendingProposals()) > 0, func() app.UI {
+					return app.H6().Class("card-title pt-3").Text("Proposed Categories")
+				}),
+				app.Range(p.pendingProposals()).Slice(func(i int) app.UI {
+					cp := p.pendingProposals()[i]
+					info := strconv.Itoa(len(cp.Endorsements)) + "/" + strconv.Itoa(economy.ProposalQuorum) + " endorsements"
+					if cp.Category.Parent != "" {
+						info = "under " + p.taxonomy.Name(cp.Category.Parent) + " - " + info
+					}
+					return app.Div().Class("d-flex flex-row align-items-center p-2").Body(
+						app.Span().Class("badge rounded-pill bg-secondary").Text(strings.ToUpper(cp.Category.Name)),
+						app.Small().Class("card-text ps-2 pe-2").Text(info),
+						app.Button().Class("btn btn-outline-primary btn-sm rounded-pill").Value(cp.ID).Body(app.Text("Endorse")).Disabled(cp.Endorsed(p.citizenID)).OnClick(p.endorseCategory),
+					)
+				}),
+				app.If(p.rejected.total() > 0, func() app.UI {
+					return app.Small().Class("d-block text-muted pt-3").Title(p.rejected.String()).Text(strconv.Itoa(p.rejected.total()) + " invalid messages and records ignored")
+				}),
 				// app.Button().Class("btn btn-outline-secondary").ID("FetchAllRequests").Body(app.Text("Get Requests")).OnClick(p.FetchAllRequests),
 				// app.Button().Class("btn btn-outline-warning").ID("dummydata").Body(app.Text("Dummy Data")).OnClick(p.dummyData),
 				// app.Button().Class("btn btn-outline-danger").ID("deleteRequests").Body(app.Text("Delete Requests")).OnClick(p.deleteRequests),
 			),
 			app.Div().ID("secondary").Class("container").Body(
-				app.Button().Class("btn btn-outline-info category").Text("Other").Value("Other").OnClick(p.onSelectCategory),
-				app.Button().Class("btn btn-outline-info category").Text("Housing").Value("Housing").OnClick(p.onSelectCategory),
-				app.Button().Class("btn btn-outline-info category").Text("Food").Value("Food").OnClick(p.onSelectCategory),
-				app.Button().Class("btn btn-outline-info category").Text("Water").Value("Water").OnClick(p.onSelectCategory),
-				app.Button().ID("category-all").Class("btn btn-outline-info category active").Text("All").Value("All").OnClick(p.onSelectCategory),
+				app.Range(p.categoryButtons()).Slice(func(i int) app.UI {
+					c := p.categoryButtons()[i]
+					return app.Button().Class("btn btn-outline-info category").Value(c.ID).Title(p.totalTitle(c.ID)).Body(
+						app.If(p.taxonomy.Icon(c.ID) != "", func() app.UI {
+							return app.I().Class("fa-solid " + p.taxonomy.Icon(c.ID) + " me-1")
+						}),
+						app.Text(c.Name),
+					).OnClick(p.onSelectCategory)
+				}),
+				app.Button().ID("category-all").Class("btn btn-outline-info category active").Text("All").Value(economy.CategoryAll).OnClick(p.onSelectCategory),
 				app.Button().ID("global-stats").Class("btn btn-outline-info stats active").Text("Global Stats").Value("Global").OnClick(p.onSelectStats),
 				app.Button().ID("ranks").Class("btn btn-outline-info ranks").Text("Ranks").Value("Ranks").OnClick(p.onSelectRanks),
 				app.Button().Class("btn btn-outline-info period").Text("1 Year").Value(Year).OnClick(p.onSelectPeriod),
@@ -596,416 +683,87 @@ func (p *pubsub) Render() app.UI {
 				app.Button().Class("btn btn-outline-info period").Text("1 Day").Value(Day).OnClick(p.onSelectPeriod),
 				app.Button().ID("period-hour").Class("btn btn-outline-info period active").Text("1 Hour").Value(Hour).OnClick(p.onSelectPeriod),
 				app.Button().ID("my-stats").Class("btn btn-outline-info stats").Text("My Stats").Value("Personal").OnClick(p.onSelectStats),
-				app.Div().Class("content running").Body(
+				app.Div().Class("content").Body(
+					app.If(p.showRanks, func() app.UI {
+						return app.Select().Class("form-select mb-2").Aria("label", "Scoring").Body(
+							p.scoringOptions()...,
+						).OnChange(p.onScoring)
+					}),
+					app.If(p.showRanks && p.scoring.Strategy == economy.ScoringDecayed, func() app.UI {
+						return app.Div().Class("input-group mb-2").Body(
+							app.Span().Class("input-group-text").Text("Half-life"),
+							app.Input().Class("form-control").Type("number").Min(1).Value(p.halfLifeDays()).Aria("label", "Half-life in days").OnChange(p.onHalfLife),
+							app.Span().Class("input-group-text").Text("days"),
+						)
+					}),
+					app.If(p.showRanks, func() app.UI {
+						return app.Select().Class("form-select mb-2").Aria("label", "History period").Body(
+							p.historyPeriodOptions()...,
+						).OnChange(p.onHistoryPeriod)
+					}),
+					app.If(p.showRanks, func() app.UI {
+						return p.historyTable()
+					}),
+					app.If(p.showRanks, func() app.UI {
+						return app.Div().Class("form-check form-switch ms-2 mb-2").Body(
+							app.Input().ID("discounted").Class("form-check-input").Type("checkbox").Checked(p.discounted).OnChange(p.onRankingMode),
+							app.Label().Class("form-check-label").For("discounted").Text("Discount suspicious and unvouched citizens"),
+						)
+					}),
 					app.If(p.showRanks, func() app.UI {
 						return app.Ol().Class("list-group list-group-numbered").Body(
 							app.Range(p.ranks).Slice(func(i int) app.UI {
 								var class string
-								if p.ranks[i].citizenID == p.citizenID {
+								if p.ranks[i].CitizenID == p.citizenID {
 									class = "active"
 								}
-								if p.ranks[i].citizenID == "" {
+								if p.ranks[i].CitizenID == "" {
 									return nil
 								}
 
 								return app.Li().Class("list-group-item "+class+" d-flex justify-content-between align-items-start").Body(
 									app.Div().Class("ms-2 me-auto").Body(
 										app.Div().Class("fw-bold").Text("Citizen"),
-										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].citizenID),
+										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].CitizenID),
+										app.If(len(p.suspicious[p.ranks[i].CitizenID]) > 0, func() app.UI {
+											return app.Span().Class("badge bg-warning text-dark rounded-pill ms-1").Title(strings.Join(p.suspicious[p.ranks[i].CitizenID], ", ")).Text("suspicious")
+										}),
+										app.If(p.ranks[i].CitizenID != p.citizenID, func() app.UI {
+											return app.Button().Class("btn btn-outline-primary btn-sm rounded-pill ms-1").Value(p.ranks[i].CitizenID).Body(app.Text("Vouch")).Disabled(p.vouched(p.ranks[i].CitizenID)).OnClick(p.vouchFor)
+										}),
+										app.Button().Class("btn btn-outline-secondary btn-sm rounded-pill ms-1").Value(p.ranks[i].CitizenID).Body(app.Text("History")).OnClick(p.onHistory),
 									),
 									app.Div().Class("ms-2 me-auto").Body(
 										app.Div().Class("fw-bold").Text("Demand"),
-										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].demandRatio),
+										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].DemandRatio),
 									),
 									app.Div().Class("ms-2 me-auto").Body(
 										app.Div().Class("fw-bold").Text("Supply"),
-										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].supplyRatio),
+										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].SupplyRatio),
 									),
 									app.Div().Class("ms-2 me-auto").Body(
 										app.Div().Class("fw-bold").Text("Reputation"),
-										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].reputationIndex),
+										app.Span().Class("badge bg-primary rounded-pill").Text(p.ranks[i].ReputationIndex),
 									),
 								)
 							}),
 						)
 					}),
-					app.If(p.showRatio, func() app.UI {
-						return app.Range(p.ratio).Slice(func(i int) app.UI {
-							return app.Div().Class("range").Style("top", strconv.Itoa(390-(p.ratio[i]*40))+"px").Style("left", "0").Body(
-								app.A().Href("#").Body(app.Small().Text("Supply/Demand Ratio: " + fmt.Sprintf("%.1f", float32(p.ratio[i])/10))),
-							)
-						})
+					app.If(p.showChart, func() app.UI {
+						return p.chartControls(time.Now())
 					}),
-					app.If(p.showTime, func() app.UI {
-						return app.Range(p.timeRange).Slice(func(i int) app.UI {
-							return app.Div().Class("time").Style("top", "390px").Style("left", strconv.Itoa(p.timeRange[i]*p.multiplyer)+"px").Body(
-								app.A().Href("#").Body(app.Small().Text(p.timeFormat[i])),
-							)
-						})
+					app.If(p.showChart && p.compare, func() app.UI {
+						return p.chartLegend(p.chartSeries(time.Now()))
 					}),
 					app.If(p.showChart, func() app.UI {
-						return app.If(len(p.filteredRequests) > 0, func() app.UI {
-							return app.Range(p.filteredRequests).Slice(func(i int) app.UI {
-								if p.stats == "Personal" && p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CitizenID != p.citizenID {
-									return nil
-								}
-
-								t := time.Now()
-								// defining duration
-								d := (10 * time.Minute)
-								t = t.Round(d)
-
-								var left int
-
-								switch p.period {
-								case "hour":
-									index := 6
-									tt := t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt)
-									if tt.Minutes() >= 60 || tt.Minutes() < 0 {
-										p.filteredandValidRequests--
-										return nil
-									}
-
-									if p.filteredandValidRequests < 2 {
-										return nil
-									}
-
-									p.validRequests = append(p.validRequests, p.filteredRequests[i])
-									if p.filteredRequests[i] == p.validRequests[0] {
-										for n := 1; n < 6; n++ {
-											switch m := tt.Minutes(); {
-											case int(m) >= 10*n:
-												index--
-											}
-										}
-
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i+1])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt).Minutes() < 10 {
-											return nil
-										}
-									} else {
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt).Minutes() < 10 {
-											p.counterSameTime++
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-											p.hasChildren = true
-											return nil
-										}
-
-										var ttt time.Duration
-
-										if p.hasChildren {
-											ttt = t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt)
-										} else {
-											ttt = tt
-										}
-
-										for n := 1; n < 6; n++ {
-											switch m := ttt.Minutes(); {
-											case int(m) >= 10*n:
-												index--
-											}
-										}
-									}
-
-									left = index * p.multiplyer
-								case "day":
-									index := 24
-									t = time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.Local)
-									tt := t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt)
-									if tt.Hours() > 24 || tt.Hours() < 0 {
-										p.filteredandValidRequests--
-										return nil
-									}
-
-									if p.filteredandValidRequests < 2 {
-										return nil
-									}
-
-									p.validRequests = append(p.validRequests, p.filteredRequests[i])
-
-									if p.filteredRequests[i] == p.validRequests[0] {
-										index = p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Hour()
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i+1])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt).Hours() < 1 {
-											return nil
-										}
-									} else {
-										index = p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt.Hour()
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt).Hours() < 1 {
-											p.counterSameTime++
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-											p.hasChildren = true
-											return nil
-										} else {
-											if !p.hasChildren {
-												index = p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Hour()
-											}
-										}
-									}
-
-									left = index * p.multiplyer
-								case "week":
-									index := 7
-									t = time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.Local)
-									tt := t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt)
-									if tt.Hours() > 168 || tt.Hours() < 0 {
-										p.filteredandValidRequests--
-										return nil
-									}
-
-									if p.filteredandValidRequests < 2 {
-										return nil
-									}
-
-									p.validRequests = append(p.validRequests, p.filteredRequests[i])
-
-									if p.filteredRequests[i] == p.validRequests[0] {
-										for n := 1; n < 7; n++ {
-											switch h := tt.Hours(); {
-											case int(h) > 24*n:
-												index--
-											}
-										}
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i+1])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt).Hours() < 24 {
-											return nil
-										}
-									} else {
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt).Hours() < 24 {
-											p.counterSameTime++
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-											p.hasChildren = true
-											return nil
-										}
-
-										var ttt time.Duration
-
-										if p.hasChildren {
-											ttt = t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt)
-										} else {
-											ttt = tt
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-										}
-
-										for n := 1; n < 7; n++ {
-											switch m := ttt.Hours(); {
-											case int(m) > 24*n:
-												index--
-											}
-										}
-									}
-
-									left = index * p.multiplyer
-								case "month":
-									index := 30
-									t = time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.Local)
-									tt := t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt)
-									if tt.Hours() > 30*24 || tt.Hours() < 0 {
-										p.filteredandValidRequests--
-										return nil
-									}
-
-									if p.filteredandValidRequests < 2 {
-										return nil
-									}
-
-									p.validRequests = append(p.validRequests, p.filteredRequests[i])
-									if p.filteredRequests[i] == p.validRequests[0] {
-										for n := 1; n < 30; n++ {
-											switch h := tt.Hours(); {
-											case int(h) > 24*n:
-												index--
-											}
-										}
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i+1])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt).Hours() < 24 {
-											return nil
-										}
-									} else {
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt).Hours() < 24 {
-											p.counterSameTime++
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-											p.hasChildren = true
-											return nil
-										}
-
-										var ttt time.Duration
-
-										if p.hasChildren {
-											ttt = t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt)
-										} else {
-											ttt = tt
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-										}
-
-										for n := 1; n < 30; n++ {
-											switch m := ttt.Hours(); {
-											case int(m) > 24*n:
-												index--
-											}
-										}
-									}
-
-									left = index * p.multiplyer
-								case "year":
-									index := 12
-									t = time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 24, 0, 0, 0, time.Local)
-									tt := t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt)
-									if tt.Hours() > 356*24 || tt.Hours() < 0 {
-										p.filteredandValidRequests--
-										return nil
-									}
-
-									if p.filteredandValidRequests < 2 {
-										return nil
-									}
-
-									p.validRequests = append(p.validRequests, p.filteredRequests[i])
-									if p.filteredRequests[i] == p.validRequests[0] {
-										for n := 1; n < 12; n++ {
-											switch h := tt.Hours(); {
-											case int(h) > 30*24*n:
-												index--
-											}
-										}
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i+1])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt).Hours() < 30*24 {
-											return nil
-										}
-									} else {
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt).Hours() < 30*24 {
-											p.counterSameTime++
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-											p.hasChildren = true
-											return nil
-										}
-
-										var ttt time.Duration
-
-										if p.hasChildren {
-											ttt = t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i-1])].CreatedAt)
-										} else {
-											ttt = tt
-											if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-												p.counterSameTimeFulfilled++
-											}
-										}
-
-										for n := 1; n < 12; n++ {
-											switch m := ttt.Hours(); {
-											case int(m) > 30*24*n:
-												index--
-											}
-										}
-									}
-
-									left = index * p.multiplyer
-								}
-
-								ratio := (float64(p.counterSameTimeFulfilled) / float64(p.counterSameTime)) * 10
-
-								if t.Sub(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].CreatedAt).Minutes() <= 10 {
-									if ratio/10 < 0.6 {
-										p.globalEvent = true
-										p.resource = strings.ToLower(p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Category)
-									}
-								}
-								top := 390 - int(ratio)*40
-								p.coordinates = append(p.coordinates, coordinate{id: i, top: top, left: left})
-								if len(p.coordinates) == 1 {
-									requests := strconv.Itoa(p.counterSameTime)
-									p.counterSameTime = 0
-									p.counterSameTimeFulfilled = 0
-									p.counterSameTime++
-									if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-										p.counterSameTimeFulfilled++
-									}
-
-									return app.Div().ID("ball"+strconv.Itoa(p.filteredRequests[i])).Class("ball").Style("top", strconv.Itoa(top)+"px").Style("left", strconv.Itoa(left)+"px").Body(
-										app.A().Href("#").Body(app.Small().Text(requests+" Requests")),
-										app.Div().ID("pulse"+strconv.Itoa(p.filteredRequests[i])).Class("pulse").Style("top", "-1px").Style("left", "-1px"),
-									)
-								} else {
-									last := false
-									switch id := p.demandRequests[strconv.Itoa(p.filteredRequests[i])].ID; {
-									case id == p.lastWaterRequest:
-										last = true
-									case id == p.lastFoodRequest:
-										last = true
-									case id == p.lastHousingRequest:
-										last = true
-									case id == p.lastOtherRequest:
-										last = true
-									default:
-										last = false
-									}
-
-									if last && !p.hasChildren {
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-									}
-
-									ratio := (float64(p.counterSameTimeFulfilled) / float64(p.counterSameTime)) * 10
-									top := 390 - int(ratio)*40
-									prevElement := p.coordinates[len(p.coordinates)-2]
-									topDiff := float64(top) - float64(prevElement.top)
-									leftDiff := float64(left) - float64(prevElement.left)
-									p.coordinates[len(p.coordinates)-1].distance = math.Sqrt(topDiff*topDiff + leftDiff*leftDiff)
-									p.coordinates[len(p.coordinates)-1].angle = math.Atan2(float64(prevElement.top)-float64(top), float64(prevElement.left)-float64(left)) * (180 / math.Pi)
-									requests := strconv.Itoa(p.counterSameTime)
-									p.counterSameTime = 0
-									p.counterSameTimeFulfilled = 0
-									if !last {
-										p.counterSameTime++
-										if p.demandRequests[strconv.Itoa(p.filteredRequests[i])].Fulfilled {
-											p.counterSameTimeFulfilled++
-										}
-									}
-
-									return app.Div().ID("ball"+strconv.Itoa(p.filteredRequests[i])).Class("ball").Style("top", strconv.Itoa(top)+"px").Style("left", strconv.Itoa(left)+"px").Body(
-										app.A().Href("#").Body(app.Small().Text(requests+" Requests")),
-										app.Div().ID("pulse"+strconv.Itoa(p.filteredRequests[i])).Class("pulse").Style("top", "-1px").Style("left", "-1px"),
-										app.Div().ID("line"+strconv.Itoa(p.filteredRequests[i])).Class("line").Style("-webkit-transform", "rotate("+fmt.Sprintf("%.2f", p.coordinates[len(p.coordinates)-1].angle)+"deg)").Style("-webkit-transform-origin", "0 0.25em").Style("-webkit-animation", "ball 1s linear forwards").Style("width", fmt.Sprintf("%.2f", p.coordinates[len(p.coordinates)-1].distance)+"px"),
-									)
-								}
-							})
-						})
+						return app.Div().Body(
+							&ratioChart{Series: p.chartSeries(time.Now())},
+						).OnWheel(p.onChartWheel)
+					}),
+					app.If(p.showChart, func() app.UI {
+						return app.Div().Class("mt-3").Body(
+							p.chartVolumes(time.Now()),
+						).OnWheel(p.onChartWheel)
 					}),
 				),
 			),
@@ -1037,90 +795,41 @@ func (p *pubsub) createNotification(ctx app.Context, s NotificationStatus, h, ms
 }
 
 func (p *pubsub) onSelectPeriod(ctx app.Context, e app.Event) {
-	p.showRatio = true
-	p.showTime = true
 	p.showChart = true
-	p.validRequests = []int{}
-	p.filteredandValidRequests = len(p.filteredRequests)
-	p.hasChildren = false
 	p.period = ctx.JSSrc().Get("value").String()
-	p.setTimeAxis(p.period)
 	if p.showRanks {
 		app.Window().Get("document").Call("querySelector", "#ranks").Get("classList").Call("remove", "active")
 		app.Window().Get("document").Call("querySelector", "#global-stats").Get("classList").Call("add", "active")
 		app.Window().Get("document").Call("querySelector", "#category-all").Get("classList").Call("add", "active")
 		p.showRanks = false
 	} else {
-		// remove default period active
-		app.Window().Get("document").Call("querySelector", ".period.active").Get("classList").Call("remove", "active")
+		// remove default period active, none in a custom range
+		deactivate(".period.active")
 	}
 	// set current period active
 	ctx.JSSrc().Get("classList").Call("add", "active")
-	// chart rendering
-	app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("remove", "running")
-	ctx.Async(func() {
-		// wait for rendering
-		time.Sleep(500 * time.Millisecond)
-		ctx.Dispatch(func(ctx app.Context) {
-			// reset counters before re-render
-			p.resetChartDefaults()
-			// triger chart rendering
-			app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("add", "running")
-		})
-	})
 }
 
 func (p *pubsub) onSelectCategory(ctx app.Context, e app.Event) {
-	p.showRatio = true
-	p.showTime = true
 	p.showChart = true
-	p.filteredRequests = []int{}
-	p.validRequests = []int{}
-	p.filteredandValidRequests = len(p.filteredRequests)
-	p.hasChildren = false
+	p.filteredRequests = []string{}
 	p.category = ctx.JSSrc().Get("value").String()
-	switch p.category {
-	case "All":
-		p.filteredRequests = p.index
-	case "Water":
-		p.filteredRequests = p.filteredWaterRequests
-	case "Food":
-		p.filteredRequests = p.filteredFoodRequests
-	case "Housing":
-		p.filteredRequests = p.filteredHousingRequests
-	case "Other":
-		p.filteredRequests = p.filteredOtherRequests
-	}
-	p.filteredandValidRequests = len(p.filteredRequests)
+	p.filteredRequests = p.market.Category(p.category)
 	if p.showRanks {
 		app.Window().Get("document").Call("querySelector", "#ranks").Get("classList").Call("remove", "active")
 		app.Window().Get("document").Call("querySelector", "#global-stats").Get("classList").Call("add", "active")
 		app.Window().Get("document").Call("querySelector", "#period-hour").Get("classList").Call("add", "active")
 		p.showRanks = false
-		p.setTimeAxis(Hour)
+		p.period = Hour
 	} else {
 		// remove default category active
 		app.Window().Get("document").Call("querySelector", ".category.active").Get("classList").Call("remove", "active")
 	}
 	// set current category active
 	ctx.JSSrc().Get("classList").Call("add", "active")
-	// chart rendering
-	app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("remove", "running")
-	ctx.Async(func() {
-		// wait for rendering
-		time.Sleep(500 * time.Millisecond)
-		ctx.Dispatch(func(ctx app.Context) {
-			// reset counters before re-render
-			p.resetChartDefaults()
-			// triger chart rendering
-			app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("add", "running")
-		})
-	})
 }
 
 func (p *pubsub) onSelectStats(ctx app.Context, e app.Event) {
-	p.showRatio = true
-	p.showTime = true
 	p.showChart = true
 	p.stats = ctx.JSSrc().Get("value").String()
 	if p.showRanks {
@@ -1128,25 +837,13 @@ func (p *pubsub) onSelectStats(ctx app.Context, e app.Event) {
 		app.Window().Get("document").Call("querySelector", "#category-all").Get("classList").Call("add", "active")
 		app.Window().Get("document").Call("querySelector", "#period-hour").Get("classList").Call("add", "active")
 		p.showRanks = false
-		p.setTimeAxis(Hour)
+		p.period = Hour
 	} else {
 		// remove default stats active
 		app.Window().Get("document").Call("querySelector", ".stats.active").Get("classList").Call("remove", "active")
 	}
 	// set current stats active
 	ctx.JSSrc().Get("classList").Call("add", "active")
-	// chart rendering
-	app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("remove", "running")
-	ctx.Async(func() {
-		// wait for rendering
-		time.Sleep(500 * time.Millisecond)
-		ctx.Dispatch(func(ctx app.Context) {
-			// reset counters before re-render
-			p.resetChartDefaults()
-			// triger chart rendering
-			app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("add", "running")
-		})
-	})
 }
 
 func (p *pubsub) onSelectRanks(ctx app.Context, e app.Event) {
@@ -1155,26 +852,17 @@ func (p *pubsub) onSelectRanks(ctx app.Context, e app.Event) {
 		elems.Index(i).Get("classList").Call("remove", "active")
 	}
 
-	app.Window().Get("document").Call("querySelector", ".content").Get("classList").Call("remove", "running")
 	// set current category active
 	ctx.JSSrc().Get("classList").Call("add", "active")
-	p.showRatio = false
-	p.showTime = false
 	p.showChart = false
 	p.showRanks = true
 }
 
-func (p *pubsub) resetChartDefaults() {
-	p.coordinates = []coordinate{}
-	p.counterSameTime = 0
-	p.counterSameTimeFulfilled = 0
-}
-
 func (p *pubsub) onSelect(ctx app.Context, e app.Event) {
 	m := ctx.JSSrc().Get("value").String()
 	if m != "" {
 		p.demandRequest.Category = m
-		if p.demandRequest.Details != "" && p.demandRequest.Quantity != "" {
+		if p.demandRequest.Details != "" && p.demandRequest.Quantity.Amount > 0 {
 			enableButton()
 		} else {
 			disableButton()
@@ -1182,23 +870,33 @@ func (p *pubsub) onSelect(ctx app.Context, e app.Event) {
 	} else {
 		disableButton()
 	}
-	p.resetChartDefaults()
 }
 
 func (p *pubsub) onInput(ctx app.Context, e app.Event) {
 	m := ctx.JSSrc().Get("value").String()
 
-	if m != "" {
-		p.demandRequest.Quantity = m
+	amount, err := strconv.ParseFloat(m, 64)
+	if err == nil && amount > 0 {
+		p.demandRequest.Quantity.Amount = amount
 		if p.demandRequest.Details != "" && p.demandRequest.Category != "" {
 			enableButton()
 		} else {
 			disableButton()
 		}
 	} else {
+		p.demandRequest.Quantity.Amount = 0
 		disableButton()
 	}
-	p.resetChartDefaults()
+}
+
+func (p *pubsub) onUnit(ctx app.Context, e app.Event) {
+	p.demandRequest.Quantity.Unit = strings.TrimSpace(ctx.JSSrc().Get("value").String())
+}
+
+func (p *pubsub) onDemandLocation(ctx app.Context, e app.Event) {
+	locate(ctx, func(ctx app.Context, g economy.GeoPoint) {
+		p.demandRequest.Location = &g
+	})
 }
 
 func (p *pubsub) onMessage(ctx app.Context, e app.Event) {
@@ -1206,7 +904,7 @@ func (p *pubsub) onMessage(ctx app.Context, e app.Event) {
 
 	if m != "" {
 		p.demandRequest.Details = m
-		if p.demandRequest.Category != "" && p.demandRequest.Quantity != "" {
+		if p.demandRequest.Category != "" && p.demandRequest.Quantity.Amount > 0 {
 			enableButton()
 		} else {
 			disableButton()
@@ -1214,41 +912,59 @@ func (p *pubsub) onMessage(ctx app.Context, e app.Event) {
 	} else {
 		disableButton()
 	}
-	p.resetChartDefaults()
 }
 
 func (p *pubsub) sendDemand(ctx app.Context, e app.Event) {
+	if p.demandRequest.Quantity.Unit == "" {
+		p.demandRequest.Quantity.Unit = p.taxonomy.Unit(p.demandRequest.Category)
+	}
+	if err := p.demandRequest.Quantity.Validate(); err != nil {
+		p.createNotification(ctx, NotificationWarning, "Invalid quantity!", unitHint(err))
+		return
+	}
+	p.demandRequest.Quantity = p.demandRequest.Quantity.Normalize()
+	if p.overLimit(time.Now()) {
+		p.createNotification(ctx, NotificationWarning, "Slow down!", "You can create "+strconv.Itoa(p.sybil.RateLimit)+" demands every "+strconv.Itoa(int(p.sybil.RateWindow.Minutes()))+" minutes.")
+		return
+	}
+
 	// Publish to the `topic` through IPFS.
 	//
 	ctx.Async(func() {
 		log.Println("Publisher is about to begin...")
 		p.demandRequest.CitizenID = p.citizenID
 		p.demandRequest.Fulfilled = false
+		p.demandRequest.FulfilledBy = ""
+		p.demandRequest.Contributions = nil
+		p.demandRequest.Version = 0
 		p.demandRequest.CreatedAt = time.Now()
+		p.demandRequest.ID = newRequestID(p.demandRequest.CreatedAt)
+		err := p.demandRequest.Sign(p.key)
+		if err != nil {
+			log.Fatal(err)
+		}
 		demand, err := json.Marshal(p.demandRequest)
 		if err != nil {
 			log.Fatal(err)
 		}
 		// store in orbit-db first
-		err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(p.demandRequest.ID), []byte(demand))
+		err = p.ledger.Put(dbNameSupplyDemand, p.demandRequest.ID, demand)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = p.sh.PubSubPublish(p.topic, string(demand))
+		err = publishMessage(p.transport, p.topic, messageDemand, p.demandRequest)
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		log.Println("Finished publishing.")
-		p.createNotification(ctx, NotificationSuccess, "Demand sent!", "You have requested "+p.demandRequest.Quantity+" "+p.demandRequest.Details+" of "+p.demandRequest.Category+".")
+		p.createNotification(ctx, NotificationSuccess, "Demand sent!", "You have requested "+p.demandRequest.Quantity.String()+" of "+p.demandRequest.Category+" ("+p.demandRequest.Details+").")
 		ctx.Dispatch(func(ctx app.Context) {
-			p.showRatio = true
-			p.showTime = true
 			p.showChart = true
 			p.showRanks = false
-			p.demandRequests[strconv.Itoa(p.demandRequest.ID)] = p.demandRequest
-			p.demandRequest.ID++
+			p.market.Apply(p.demandRequest)
+			p.matches = p.bestMatches()
 		})
 	})
 }
@@ -1258,336 +974,154 @@ func (p *pubsub) sendSupply(ctx app.Context, e app.Event) {
 	// Publish to the `topic` through IPFS.
 	//
 
-	id := ctx.JSSrc().Get("id").String()
-	d := p.demandRequests[id]
-	d.Fulfilled = true
-	d.FulfilledBy = p.citizenID
-	d.FulfilledAt = time.Now()
-
-	supply, err := json.Marshal(d)
-	if err != nil {
-		log.Fatal(err)
+	id := ctx.JSSrc().Get("value").String()
+	d := p.market.Request(id)
+	// supply what remains unless a smaller amount is given
+	amount := d.Remaining()
+	if s, ok := p.suggestion(id); ok {
+		amount = s.Amount
 	}
-	ctx.Async(func() {
-		// store in orbit-db first
-		err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(d.ID), supply)
-		if err != nil {
-			log.Fatal(err)
+	if input := app.Window().GetElementByID("supply-" + id); input.Truthy() {
+		v, err := strconv.ParseFloat(input.Get("value").String(), 64)
+		if err == nil && v > 0 && v < amount {
+			amount = v
 		}
+	}
+	// the requester confirms the claim, see claim.go
+	c := supplyClaim{
+		RequestID: d.ID,
+		Version:   d.Version,
+		Supplier:  p.citizenID,
+		Amount:    amount,
+		ClaimedAt: time.Now(),
+	}
+	c.sign(p.key)
 
-		err = p.sh.PubSubPublish(p.topic, string(supply))
+	ctx.Async(func() {
+		err := publishMessage(p.transport, p.topic, messageClaim, c)
 		if err != nil {
 			log.Fatal(err)
 		}
 		ctx.Dispatch(func(ctx app.Context) {
-			p.demandRequests[id] = d
-			p.createNotification(ctx, NotificationSuccess, "Supply sent!", "You have supplied "+d.Quantity+" "+d.Details+" of "+d.Category+".")
+			p.createNotification(ctx, NotificationInfo, "Supply offered!", "Waiting for the requester to confirm your supply of "+economy.Quantity{Amount: amount, Unit: d.Quantity.Unit}.String()+" of "+d.Category+".")
 		})
 	})
 }
 
 func (p *pubsub) checkUnsuppliedMessages(ctx app.Context) {
-	p.showMessages = false
-	if len(p.demandRequests) > 0 {
-		for i := range p.index {
-			i = i + 1
-			for range p.demandRequests {
-				if !p.demandRequests[strconv.Itoa(i)].Fulfilled {
-					p.showMessages = true
-				}
-			}
-		}
-	}
+	p.showMessages = p.market.Pending()
 }
 
 func (p *pubsub) FetchAllRequests(ctx app.Context, e app.Event) {
 	ctx.Async(func() {
-		// query orbit-db
-		v, err := p.sh.OrbitKVGet(dbNameSupplyDemand, "all")
+		// query the ledger
+		ds, err := p.ledger.List(dbNameSupplyDemand)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		ds := make(map[int]string)
-		drs := p.demandRequests
-		err = json.Unmarshal(v, &ds)
+		// rewrite records stored under sequential integer IDs
+		ds, malformed, err := migrateLegacyRequests(p.ledger, ds)
 		if err != nil {
 			log.Fatal(err)
 		}
+		rotations, skipped, err := fetchRotations(p.ledger)
+		if err != nil {
+			log.Fatal(err)
+		}
+		malformed = append(malformed, skipped...)
 
-		p.index = make([]int, 0, len(ds))
-		if len(ds) > 0 {
-			for i, d := range ds {
-				p.index = append(p.index, i)
-
-				dec, err := base64.URLEncoding.DecodeString(string(d))
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				err = json.Unmarshal(dec, &p.demandRequest)
-				if err != nil {
-					log.Fatal(err)
-				}
-				if !p.demandRequest.Fulfilled {
-					p.showMessages = true
-				}
-
-				drs[strconv.Itoa(p.demandRequest.ID)] = p.demandRequest
-				if err != nil {
-					log.Fatal(err)
-				}
+		requests := make([]economy.Request, 0, len(ds))
+		for key, v := range ds {
+			d := economy.Request{}
+			err = json.Unmarshal(v, &d)
+			if err != nil {
+				malformed = append(malformed, malformedRecord(dbNameSupplyDemand, key, err))
+				continue
 			}
+			requests = append(requests, d)
 		}
 
-		sort.Ints(p.index)
-		var nextIndex int
-		if len(p.demandRequests) > 0 {
-			peerRanks := make([]ranking, 0)
-			citizenIDs := make(map[string]bool, 0)
-			var peerExists bool
-			myDemands := make(map[string]int, 0)
-			mySupplies := make(map[string]int, 0)
-			totalDemands := 0
-			totalSupplies := 0
-			r := ranking{}
-
-			for k, v := range p.index {
-				if p.citizenID == p.demandRequests[strconv.Itoa(v)].CitizenID {
-					p.newComer = false
-				}
-
-				if p.demandRequests[strconv.Itoa(v)].CitizenID != p.demandRequests[strconv.Itoa(v-1)].CitizenID {
-					totalDemands++
-					if !citizenIDs[p.demandRequests[strconv.Itoa(v)].CitizenID] {
-						myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID] = 1
-						citizenIDs[p.demandRequests[strconv.Itoa(v)].CitizenID] = true
-					} else {
-						myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID]++
-						for kk, vv := range peerRanks {
-							if vv.citizenID == p.demandRequests[strconv.Itoa(v)].CitizenID {
-								vv.demandRatio = float64(myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID])
-								peerRanks[kk].demandRatio = vv.demandRatio
-							}
-						}
-					}
-					peerRanks = append(peerRanks, r)
-				} else {
-					myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID]++
-					totalDemands++
+		ctx.Dispatch(func(ctx app.Context) {
+			for _, err := range malformed {
+				p.rejected.add(err)
+			}
+			// records signed with a new key for an old handle need the links
+			for _, r := range rotations {
+				if err := p.keys.Link(r); err != nil {
+					p.rejected.add(verified(err))
 				}
-
-				if p.demandRequests[strconv.Itoa(v)].Fulfilled {
-					mySupplies[p.demandRequests[strconv.Itoa(v)].FulfilledBy]++
-					totalSupplies++
-					for kk, vv := range peerRanks {
-						if vv.citizenID == p.demandRequests[strconv.Itoa(v)].FulfilledBy {
-							vv.supplyRatio = float64(mySupplies[p.demandRequests[strconv.Itoa(v)].FulfilledBy])
-							peerRanks[kk].supplyRatio = vv.supplyRatio
-							peerExists = true
-						}
-					}
-
-					if !peerExists {
-						r = ranking{
-							citizenID:       p.demandRequests[strconv.Itoa(v)].FulfilledBy,
-							demandRatio:     0,
-							supplyRatio:     float64(mySupplies[p.demandRequests[strconv.Itoa(v)].FulfilledBy]),
-							reputationIndex: 0,
-						}
-						peerRanks = append(peerRanks, r)
-					}
+			}
+			now := time.Now()
+			for _, d := range requests {
+				if err := validateDemand(d, p.taxonomy, now); err != nil {
+					p.rejected.add(err)
+					continue
 				}
-
-				demandRatio := float64(myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID])
-				supplyRatio := float64(mySupplies[p.demandRequests[strconv.Itoa(v)].CitizenID])
-
-				r = ranking{
-					citizenID:       p.demandRequests[strconv.Itoa(v)].CitizenID,
-					demandRatio:     demandRatio,
-					supplyRatio:     supplyRatio,
-					reputationIndex: 0,
+				err := p.keys.Verify(d)
+				if err != nil && len(d.Signer) == 0 && errors.Is(err, economy.ErrUnsigned) {
+					// stored before records were signed, kept for the charts
+					d.Legacy = true
+				} else if err != nil {
+					p.rejected.add(verified(err))
+					continue
 				}
-
-				// next index +1
-				nextIndex = p.demandRequests[strconv.Itoa(v)].ID + 1
-
-				switch p.demandRequests[strconv.Itoa(v)].Category {
-				case "Water":
-					p.lastWaterRequest = p.demandRequests[strconv.Itoa(v)].ID
-					p.filteredWaterRequests = append(p.filteredWaterRequests, p.demandRequests[strconv.Itoa(k)].ID)
-				case "Food":
-					p.lastFoodRequest = p.demandRequests[strconv.Itoa(v)].ID
-					p.filteredFoodRequests = append(p.filteredFoodRequests, p.demandRequests[strconv.Itoa(k)].ID)
-				case "Housing":
-					p.lastHousingRequest = p.demandRequests[strconv.Itoa(v)].ID
-					p.filteredHousingRequests = append(p.filteredHousingRequests, p.demandRequests[strconv.Itoa(k)].ID)
-				case "Other":
-					p.lastOtherRequest = p.demandRequests[strconv.Itoa(v)].ID
-					p.filteredOtherRequests = append(p.filteredOtherRequests, p.demandRequests[strconv.Itoa(k)].ID)
+				p.market.Apply(d)
+				if p.mine(d.CitizenID) {
+					p.newComer = false
 				}
 			}
-
-			p.filteredRequests = p.index
+			p.matches = p.bestMatches()
+			if p.market.Len() == 0 {
+				return
+			}
+			p.showMessages = p.market.Pending()
+			p.ranks = p.rank()
+			p.filteredRequests = p.market.IDs()
 			p.showChart = true
-			p.filteredandValidRequests = len(p.filteredRequests)
 			// send welcome notification to newcomers
 			if p.newComer {
 				p.createNotification(ctx, NotificationPrimary, "Welcome to Cyber Stasis!", "Read How to Play to learn the basics. Please note the game is not optimized for mobile devices. For best experience play it on a computer.")
 			}
-
-			ctx.Dispatch(func(ctx app.Context) {
-				if len(peerRanks) > 0 {
-					for i := range peerRanks {
-						if totalDemands > 0 {
-							peerRanks[i].demandRatio = peerRanks[i].demandRatio / float64(totalDemands)
-						} else {
-							peerRanks[i].demandRatio = 0
-						}
-						if totalSupplies > 0 {
-							peerRanks[i].supplyRatio = peerRanks[i].supplyRatio / float64(totalSupplies)
-						} else {
-							peerRanks[i].supplyRatio = 0
-						}
-						peerRanks[i].reputationIndex = (peerRanks[i].supplyRatio - peerRanks[i].demandRatio) * ((float64(myDemands[peerRanks[i].citizenID]) + float64(mySupplies[peerRanks[i].citizenID])) / float64(len(p.demandRequests)))
-						p.ranks = append(p.ranks, peerRanks[i])
-					}
-					sort.SliceStable(p.ranks, func(i, j int) bool {
-						return p.ranks[i].reputationIndex > p.ranks[j].reputationIndex
-					})
-				}
-				p.demandRequest.ID = nextIndex
-				p.demandRequests = drs
-				p.resetChartDefaults()
-			})
-		} else {
-			nextIndex = 1
-			ctx.Dispatch(func(ctx app.Context) {
-				p.demandRequest.ID = nextIndex
-			})
-		}
+		})
 	})
 }
 
 func (p *pubsub) updateRanks(ctx app.Context) {
-	p.ranks = make([]ranking, 0)
-	peerRanks := make([]ranking, 0)
-	citizenIDs := make(map[string]bool, 0)
-	var peerExists bool
-	myDemands := make(map[string]int, 0)
-	mySupplies := make(map[string]int, 0)
-	totalDemands := 0
-	totalSupplies := 0
-	r := ranking{}
-	for _, v := range p.index {
-		if p.citizenID == p.demandRequests[strconv.Itoa(v)].CitizenID {
+	p.ranks = p.rank()
+	for _, r := range p.ranks {
+		if r.CitizenID == p.citizenID {
 			p.newComer = false
 		}
-
-		if p.demandRequests[strconv.Itoa(v)].CitizenID != p.demandRequests[strconv.Itoa(v-1)].CitizenID {
-			totalDemands++
-			if !citizenIDs[p.demandRequests[strconv.Itoa(v)].CitizenID] {
-				myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID] = 1
-				citizenIDs[p.demandRequests[strconv.Itoa(v)].CitizenID] = true
-			} else {
-				myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID]++
-				for kk, vv := range peerRanks {
-					if vv.citizenID == p.demandRequests[strconv.Itoa(v)].CitizenID {
-						vv.demandRatio = float64(myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID])
-						peerRanks[kk].demandRatio = vv.demandRatio
-					}
-				}
-			}
-
-			peerRanks = append(peerRanks, r)
-		} else {
-			myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID]++
-			totalDemands++
-		}
-
-		if p.demandRequests[strconv.Itoa(v)].Fulfilled {
-			mySupplies[p.demandRequests[strconv.Itoa(v)].FulfilledBy]++
-			totalSupplies++
-			for kk, vv := range peerRanks {
-				if vv.citizenID == p.demandRequests[strconv.Itoa(v)].FulfilledBy {
-					vv.supplyRatio = float64(mySupplies[p.demandRequests[strconv.Itoa(v)].FulfilledBy])
-					peerRanks[kk].supplyRatio = vv.supplyRatio
-					peerExists = true
-				}
-			}
-
-			if !peerExists {
-				r = ranking{
-					citizenID:       p.demandRequests[strconv.Itoa(v)].FulfilledBy,
-					demandRatio:     0,
-					supplyRatio:     float64(mySupplies[p.demandRequests[strconv.Itoa(v)].FulfilledBy]),
-					reputationIndex: 0,
-				}
-				peerRanks = append(peerRanks, r)
-			}
-		}
-
-		demandRatio := float64(myDemands[p.demandRequests[strconv.Itoa(v)].CitizenID])
-		supplyRatio := float64(mySupplies[p.demandRequests[strconv.Itoa(v)].CitizenID])
-
-		r = ranking{
-			citizenID:       p.demandRequests[strconv.Itoa(v)].CitizenID,
-			demandRatio:     demandRatio,
-			supplyRatio:     supplyRatio,
-			reputationIndex: 0,
-		}
-
-		if len(p.index) == 1 {
-			peerRanks = append(peerRanks, r)
+		if r.CitizenID == p.citizenID && r.ReputationIndex < 0 {
+			p.createNotification(ctx, NotificationWarning, "You can do better!", "You need to contribute more!")
 		}
 	}
-
-	ctx.Dispatch(func(ctx app.Context) {
-		if len(peerRanks) > 0 {
-			for i := range peerRanks {
-				if totalDemands > 0 {
-					peerRanks[i].demandRatio = peerRanks[i].demandRatio / float64(totalDemands)
-				} else {
-					peerRanks[i].demandRatio = 0
-				}
-				if totalSupplies > 0 {
-					peerRanks[i].supplyRatio = peerRanks[i].supplyRatio / float64(totalSupplies)
-				} else {
-					peerRanks[i].supplyRatio = 0
-				}
-				peerRanks[i].reputationIndex = (peerRanks[i].supplyRatio - peerRanks[i].demandRatio) * ((float64(myDemands[peerRanks[i].citizenID]) + float64(mySupplies[peerRanks[i].citizenID])) / float64(len(p.demandRequests)))
-				p.ranks = append(p.ranks, peerRanks[i])
-				if peerRanks[i].citizenID == p.citizenID && peerRanks[i].reputationIndex < 0 {
-					p.createNotification(ctx, NotificationWarning, "You can do better!", "You need to contribute more!")
-				}
-			}
-			sort.SliceStable(p.ranks, func(i, j int) bool {
-				return p.ranks[i].reputationIndex > p.ranks[j].reputationIndex
-			})
-		}
-		if len(p.ranks) > 1 {
-			if p.ranks[0].citizenID == p.citizenID {
-				p.createNotification(ctx, NotificationSuccess, "Well done!", "You are ranked number one!")
-			}
+	if len(p.ranks) > 1 {
+		if p.ranks[0].CitizenID == p.citizenID {
+			p.createNotification(ctx, NotificationSuccess, "Well done!", "You are ranked number one!")
 		}
+	}
 
-		p.storeRanks(ctx)
-	})
+	p.storeRanks(ctx)
 }
 
+// storeRanks persists the classic ranking, not the one of the strategy and
+// discount chosen on this dashboard, so every dashboard stores the same one.
 func (p *pubsub) storeRanks(ctx app.Context) {
+	// ranked on the UI goroutine which owns the market
+	ranks := economy.Scoring{}.Rank(p.keys.Canonical(p.market.Signed()), time.Now())
 	ctx.Async(func() {
 		cr := citizenReputation{}
-		for i, r := range p.ranks {
+		for i, r := range ranks {
 			cr.ID = strconv.Itoa(i + 1)
 			cr.Type = "reputation"
-			cr.CitizenID = r.citizenID
-			cr.ReputationIndex = r.reputationIndex
+			cr.CitizenID = r.CitizenID
+			cr.ReputationIndex = r.ReputationIndex
 			crr, err := json.Marshal(cr)
 			if err != nil {
 				log.Fatal(err)
 			}
-			err = p.sh.OrbitDocsPut(dbNameCitizenReputation, crr)
+			err = p.ledger.Put(dbNameCitizenReputation, cr.ID, crr)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1599,15 +1133,16 @@ func (p *pubsub) storeRanks(ctx app.Context) {
 func (p *pubsub) deleteRequests(ctx app.Context, e app.Event) {
 	ctx.Async(func() {
 		// query orbit-db
-		err := p.sh.OrbitKVDelete(dbNameSupplyDemand, "all")
+		err := p.ledger.Clear(dbNameSupplyDemand)
 		if err != nil {
 			log.Fatal(err)
 		}
 		ctx.Dispatch(func(ctx app.Context) {
-			p.index = make([]int, 0)
-			p.filteredRequests = make([]int, 0)
-			p.demandRequests = map[string]demandRequest{}
-			p.ranks = make([]ranking, 0)
+			p.market = economy.NewLedger()
+			p.market.SetTaxonomy(p.taxonomy)
+			p.matches = nil
+			p.filteredRequests = make([]string, 0)
+			p.ranks = make([]economy.Ranking, 0)
 			p.showMessages = false
 			p.showChart = false
 			// p.Update()
@@ -1615,441 +1150,31 @@ func (p *pubsub) deleteRequests(ctx app.Context, e app.Event) {
 	})
 }
 
-func (p *pubsub) createDummyRequestHour(n, i, f, id int) {
-	dr := demandRequest{}
-	dr.ID = id
-	dr.Quantity = strconv.Itoa(id)
-
-	var tb int
-	t := time.Now()
-	if i > 0 {
-		tb = t.Hour() - 1
-	} else {
-		tb = t.Hour()
-	}
-	tt := t.Add(time.Minute - 10)
-	d := (10 * time.Minute)
-	dr.CreatedAt = time.Date(t.Year(), t.Month(), t.Day(), tb, tt.Round(d).Minute()+(i*10), 0, 0, time.Local)
-	switch n {
-	case n % 2:
-		dr.Category = "Housing"
-		dr.Details = "accommodation"
-	case n % 3:
-		dr.Category = "Food"
-		dr.Details = "kg"
-	default:
-		dr.Category = "Water"
-		dr.Details = "litres"
-	}
-
-	if f > n {
-		dr.Fulfilled = true
-		if i > 0 && i < 5 {
-			dr.FulfilledBy = strconv.Itoa(i + 1)
-		} else {
-			dr.FulfilledBy = strconv.Itoa(1)
-		}
-		dr.FulfilledAt = time.Date(t.Year(), t.Month(), t.Day(), tb, tt.Round(d).Minute()+(i*10), 0, 0, time.Local)
-	}
-
-	dr.CitizenID = strconv.Itoa(i)
-
-	demand, err := json.Marshal(dr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// store in orbit-db first
-	err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(id), demand)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = p.sh.PubSubPublish(p.topic, string(demand))
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func (p *pubsub) createDummyRequestDay(n, i, f, id int) {
-	dr := demandRequest{}
-	dr.ID = id
-	dr.Quantity = strconv.Itoa(id)
-	if i == 0 {
-		i = 23
-	}
-	t := time.Now()
-	dr.CreatedAt = time.Date(t.Year(), t.Month(), t.Day(), i, 0, 0, 0, time.Local)
-	switch n {
-	case n % 2:
-		dr.Category = "Food"
-		dr.Details = "kg"
-	case n % 3:
-		dr.Category = "Housing"
-		dr.Details = "accommodation"
-	default:
-		dr.Category = "Water"
-		dr.Details = "litres"
-	}
-
-	if f > n {
-		dr.Fulfilled = true
-		if i < 20 {
-			dr.FulfilledBy = strconv.Itoa(i + 10)
-		} else {
-			dr.FulfilledBy = strconv.Itoa(2)
-		}
-		dr.FulfilledAt = time.Date(t.Year(), t.Month(), t.Day(), i, 0, 0, 0, time.Local)
-	}
-
-	dr.CitizenID = strconv.Itoa(i)
-
-	demand, err := json.Marshal(dr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// store in orbit-db first
-	err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(id), demand)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = p.sh.PubSubPublish(p.topic, string(demand))
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func (p *pubsub) createDummyRequestWeek(n, i, f, id int) {
-	dr := demandRequest{}
-	dr.ID = id
-	dr.Quantity = strconv.Itoa(id)
-	if i == 0 {
-		i = 7
-	}
-	t := time.Now().AddDate(0, 0, -7)
-	dr.CreatedAt = time.Date(t.Year(), t.Month(), t.Day()+i, i, 0, 0, 0, time.Local)
-	switch n {
-	case n % 2:
-		dr.Category = "Housing"
-		dr.Details = "accommodation"
-	case n % 3:
-		dr.Category = "Food"
-		dr.Details = "kg"
-	default:
-		dr.Category = "Water"
-		dr.Details = "litres"
-	}
-
-	if f > n {
-		dr.Fulfilled = true
-		if i < 7 {
-			dr.FulfilledBy = strconv.Itoa(i + 1)
-		} else {
-			dr.FulfilledBy = strconv.Itoa(1)
-		}
-		dr.FulfilledAt = time.Date(t.Year(), t.Month(), t.Day()+i, i, 0, 0, 0, time.Local)
-	}
-
-	dr.CitizenID = strconv.Itoa(i)
-
-	demand, err := json.Marshal(dr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// store in orbit-db first
-	err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(id), demand)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = p.sh.PubSubPublish(p.topic, string(demand))
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func (p *pubsub) createDummyRequestMonth(n, i, f, id int) {
-	dr := demandRequest{}
-	dr.ID = id
-	dr.Quantity = strconv.Itoa(id)
-	if i == 0 {
-		i = 30
-	}
-	t := time.Now().AddDate(0, 0, -30)
-	dr.CreatedAt = time.Date(t.Year(), t.Month(), t.Day()+i, i, 0, 0, 0, time.Local)
-	switch n {
-	case n % 2:
-		dr.Category = "Housing"
-		dr.Details = "accommodation"
-	case n % 3:
-		dr.Category = "Food"
-		dr.Details = "kg"
-	default:
-		dr.Category = "Water"
-		dr.Details = "litres"
-	}
-
-	if f > n {
-		dr.Fulfilled = true
-		if i < 30 {
-			dr.FulfilledBy = strconv.Itoa(i + 1)
-		} else {
-			dr.FulfilledBy = strconv.Itoa(1)
-		}
-		dr.FulfilledAt = time.Date(t.Year(), t.Month(), t.Day()+i, i, 0, 0, 0, time.Local)
-	}
-
-	dr.CitizenID = strconv.Itoa(i)
-
-	demand, err := json.Marshal(dr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// store in orbit-db first
-	err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(id), demand)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = p.sh.PubSubPublish(p.topic, string(demand))
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func (p *pubsub) createDummyRequestYear(n, i, f, id int) {
-	dr := demandRequest{}
-	dr.ID = id
-	dr.Quantity = strconv.Itoa(id)
-	if i == 0 {
-		i = 12
-	}
-	t := time.Now().AddDate(-1, i, 0)
-	dr.CreatedAt = t
-	switch n {
-	case n % 2:
-		dr.Category = "Housing"
-		dr.Details = "accommodation"
-	case n % 3:
-		dr.Category = "Food"
-		dr.Details = "kg"
-	default:
-		dr.Category = "Water"
-		dr.Details = "litres"
-	}
-
-	if f > n {
-		dr.Fulfilled = true
-		if i < 12 {
-			dr.FulfilledBy = strconv.Itoa(i + 1)
-		} else {
-			dr.FulfilledBy = strconv.Itoa(1)
-		}
-		dr.FulfilledAt = t
-	}
-
-	dr.CitizenID = strconv.Itoa(i)
-
-	demand, err := json.Marshal(dr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// store in orbit-db first
-	err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(id), demand)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = p.sh.PubSubPublish(p.topic, string(demand))
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func (p *pubsub) createDummyRequestCustom(n, i, f, id int) {
-	dr := demandRequest{}
-	dr.ID = id
-	dr.Quantity = strconv.Itoa(id)
-	t := time.Now()
-	dr.CreatedAt = t
-	switch n {
-	// case n % 2:
-	// 	dr.Category = "Housing"
-	// 	dr.Details = "accommodation"
-	// case n % 3:
-	// 	dr.Category = "Food"
-	// 	dr.Details = "kg"
-	default:
-		dr.Category = "Water"
-		dr.Details = "litres"
-	}
-
-	dr.CitizenID = p.citizenID
-
-	demand, err := json.Marshal(dr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// store in orbit-db first
-	err = p.sh.OrbitKVPut(dbNameSupplyDemand, strconv.Itoa(id), demand)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = p.sh.PubSubPublish(p.topic, string(demand))
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
+// dummyData fills the ledger with the default scenario of the selected period.
 func (p *pubsub) dummyData(ctx app.Context, e app.Event) {
-	switch p.period {
-	case "hour":
-		p.dummyDataHour(ctx, e)
-	case "day":
-		p.dummyDataDay(ctx, e)
-	case "week":
-		p.dummyDataWeek(ctx, e)
-	case "month":
-		p.dummyDataMonth(ctx, e)
-	case "year":
-		p.dummyDataYear(ctx, e)
-	case "custom":
-		p.dummyDataCustom(ctx, e)
-	}
-}
-
-func (p *pubsub) dummyDataHour(ctx app.Context, e app.Event) {
-	ctx.Async(func() {
-		i := 1
-		f := 1
-		id := 1
-		n := 0
-		for i < 6 {
-			for n := n; n < 3; n++ {
-				p.createDummyRequestHour(n, i, f, id)
-				id++
-			}
-			i = i + 2
-			f++
-		}
-
-		p.createDummyRequestHour(n, 0, f, id)
-	})
-}
-
-func (p *pubsub) dummyDataDay(ctx app.Context, e app.Event) {
-	ctx.Async(func() {
-		i := 2
-		f := 1
-		id := 1
-		n := 0
-		for i < 24 {
-			for n := n; n < 10; n++ {
-				p.createDummyRequestDay(n, i, f, id)
-				id++
-			}
-			i += 10
-			f++
-		}
-
-		p.createDummyRequestDay(n, 0, f, id)
-	})
-}
-
-func (p *pubsub) dummyDataWeek(ctx app.Context, e app.Event) {
+	s := simulation.DefaultScenario(periodSpan(p.period))
 	ctx.Async(func() {
-		i := 1
-		f := 1
-		id := 1
-		n := 0
-		for i < 7 {
-			for n := n; n < 3; n++ {
-				p.createDummyRequestWeek(n, i, f, id)
-				id++
-			}
-			i = i + 2
-			f++
+		requests, err := simulation.Generate(s, time.Now())
+		if err != nil {
+			log.Fatal(err)
 		}
-
-		p.createDummyRequestWeek(n, 0, f, id)
-	})
-}
-
-func (p *pubsub) dummyDataMonth(ctx app.Context, e app.Event) {
-	ctx.Async(func() {
-		i := 1
-		f := 1
-		id := 1
-		n := 0
-		for i < 30 {
-			for n := n; n < 3; n++ {
-				p.createDummyRequestMonth(n, i, f, id)
-				id++
+		for _, r := range requests {
+			err = putRequest(p.ledger, r)
+			if err != nil {
+				log.Fatal(err)
 			}
-			i += 10
-			f++
-		}
-
-		p.createDummyRequestMonth(n, 0, f, id)
-	})
-}
 
-func (p *pubsub) dummyDataYear(ctx app.Context, e app.Event) {
-	ctx.Async(func() {
-		i := 2
-		f := 1
-		id := 1
-		n := 0
-		for i < 12 {
-			for n := n; n < 3; n++ {
-				p.createDummyRequestYear(n, i, f, id)
-				id++
+			err = publishMessage(p.transport, p.topic, messageDemand, r)
+			if err != nil {
+				log.Fatal(err)
 			}
-			i += 4
-			f++
-		}
-
-		p.createDummyRequestYear(n, 0, f, id)
-	})
-}
-
-func (p *pubsub) dummyDataCustom(ctx app.Context, e app.Event) {
-	ctx.Async(func() {
-		i := 1
-		f := 1
-		id := 1
-		n := 0
-		for i < 11 {
-			p.createDummyRequestCustom(n, i, f, id)
-			id++
-			i++
-			f++
 		}
 	})
 }
 
 func (p *pubsub) subscribe(ctx app.Context) {
 	ctx.Async(func() {
-		subscription, err := p.sh.PubSubSubscribe(p.topic)
+		subscription, err := p.transport.Subscribe(p.topic)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -2060,7 +1185,6 @@ func (p *pubsub) subscribe(ctx app.Context) {
 
 func (p *pubsub) subscription(ctx app.Context) {
 	ctx.Async(func() {
-		defer p.sub.Cancel()
 		// wait on pubsub
 		res, err := p.sub.Next()
 		if err != nil {
@@ -2069,22 +1193,60 @@ func (p *pubsub) subscription(ctx app.Context) {
 		// Decode the string data.
 		str := string(res.Data)
 		ctx.Async(func() {
-			p.subscribe(ctx)
+			p.subscription(ctx)
 		})
 		ctx.Dispatch(func(ctx app.Context) {
-			d := demandRequest{}
-			err := json.Unmarshal([]byte(str), &d)
+			m, err := decodeMessage([]byte(str), p.taxonomy, p.keys, time.Now())
 			if err != nil {
-				log.Fatal(err)
+				p.reject(err)
+				return
 			}
 
-			citizenID := res.From[len(res.From)-8:]
-			if citizenID.String() != p.citizenID {
-				p.demandRequests[strconv.Itoa(d.ID)] = d
+			var d economy.Request
+			switch m.Type {
+			case messageDemand, messageConfirm:
+				d = m.Payload.(economy.Request)
+				if m.Type == messageDemand && !p.market.Has(d.ID) && !p.limiter.allow(d.CitizenID, time.Now(), p.sybil) {
+					p.reject(fmt.Errorf("%w: %s", errRateLimited, d.CitizenID))
+					return
+				}
+				if !p.market.Apply(d) {
+					return
+				}
+				p.matches = p.bestMatches()
+				if cs := d.Contributions; m.Type == messageConfirm && len(cs) > 0 && cs[len(cs)-1].Supplier == p.citizenID {
+					supplied := economy.Quantity{Amount: cs[len(cs)-1].Amount, Unit: d.Quantity.Unit}
+					p.createNotification(ctx, NotificationSuccess, "Supply sent!", "You have supplied "+supplied.String()+" of "+d.Category+".")
+				}
+				if m.Type == messageConfirm {
+					p.recordMatches(ctx, d)
+				} else {
+					p.matchOffers(ctx, d)
+				}
+			case messageVouch:
+				v := m.Payload.(economy.Vouch)
+				p.vouches[v.Key()] = v
+				p.ranks = p.rank()
+				return
+			case messageRotation:
+				if err := p.keys.Link(m.Payload.(economy.Rotation)); err != nil {
+					p.reject(verified(err))
+					return
+				}
+				p.ranks = p.rank()
+				return
+			case messageClaim:
+				p.arbitrateClaim(ctx, m.Payload.(supplyClaim))
+				return
+			case messageReject:
+				p.applyRejection(ctx, m.Payload.(claimRejection))
+				return
+			default:
+				p.reject(fmt.Errorf("%w: %q on topic %s", errUnknownType, m.Type, p.topic))
+				return
 			}
-			p.index = append(p.index, d.ID)
-			if p.demandRequests[strconv.Itoa(d.ID)].CitizenID == p.citizenID {
-				if !p.demandRequests[strconv.Itoa(d.ID)].Fulfilled {
+			if p.mine(p.market.Request(d.ID).CitizenID) {
+				if !p.market.Request(d.ID).Fulfilled {
 					p.counterDemand++
 				} else {
 					p.counterDemand--
@@ -2104,54 +1266,26 @@ func (p *pubsub) subscription(ctx app.Context) {
 
 			}
 
-			switch p.demandRequests[strconv.Itoa(d.ID)].Category {
-			case "Water":
-				p.lastWaterRequest = p.demandRequests[strconv.Itoa(d.ID)].ID
-				p.filteredWaterRequests = append(p.filteredWaterRequests, p.demandRequests[strconv.Itoa(d.ID)].ID)
-			case "Food":
-				p.lastFoodRequest = p.demandRequests[strconv.Itoa(d.ID)].ID
-				p.filteredFoodRequests = append(p.filteredFoodRequests, p.demandRequests[strconv.Itoa(d.ID)].ID)
-			case "Housing":
-				p.lastHousingRequest = p.demandRequests[strconv.Itoa(d.ID)].ID
-				p.filteredHousingRequests = append(p.filteredHousingRequests, p.demandRequests[strconv.Itoa(d.ID)].ID)
-			case "Other":
-				p.lastOtherRequest = p.demandRequests[strconv.Itoa(d.ID)].ID
-				p.filteredOtherRequests = append(p.filteredOtherRequests, p.demandRequests[strconv.Itoa(d.ID)].ID)
-			}
-
-			switch p.category {
-			case "All":
-				p.filteredRequests = append(p.filteredRequests, d.ID)
-			case "Water":
-				p.filteredRequests = p.filteredWaterRequests
-			case "Food":
-				p.filteredRequests = p.filteredFoodRequests
-			case "Housing":
-				p.filteredRequests = p.filteredHousingRequests
-			case "Other":
-				p.filteredRequests = p.filteredOtherRequests
-			}
-
-			p.filteredandValidRequests = len(p.filteredRequests)
+			p.filteredRequests = p.market.Category(p.category)
 			p.showChart = true
 			p.showRanks = false
 
-			p.resetChartDefaults()
 			p.updateRanks(ctx)
-			if p.globalEvent {
-				header := "Global shortage of " + p.resource + "! "
-				msg := "Please supply more " + p.resource + "."
-				category := p.resource
-				s := Shortage{
+			for _, category := range economy.Shortages(p.market.Signed(), time.Now()) {
+				name := strings.ToLower(p.taxonomy.Name(category))
+				header := "Global shortage of " + name + "! "
+				msg := "Please supply more " + name + "."
+				s := economy.Shortage{
 					Category:    category,
 					Description: header,
 				}
-				shortage, err := json.Marshal(s)
-				if err != nil {
-					log.Fatal(err)
-				}
 				p.createNotification(ctx, NotificationDanger, header, msg)
-				p.sh.PubSubPublish(topicCritical, string(shortage))
+				ctx.Async(func() {
+					err := publishMessage(p.transport, topicCritical, messageShortage, s)
+					if err != nil {
+						log.Fatal(err)
+					}
+				})
 			}
 
 			p.checkUnsuppliedMessages(ctx)
@@ -2194,6 +1328,16 @@ func main() {
 	// instructions.
 	app.RunWhenOnBrowser()
 
+	// subcommands such as `cyber-stasis simulate` run instead of the server
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	// Finally, launching the server that serves the app is done by using the Go
 	// standard HTTP package.
 	//
@@ -2216,6 +1360,10 @@ func main() {
 	})
 
 	http.Handle("/", withGz)
+	http.Handle(relayPath, newRelay())
+	for path, h := range serverHandlers {
+		http.Handle(path, h())
+	}
 
 	if err := http.ListenAndServe(":3000", nil); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,289 @@
+package economy
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Periods of the charts. The buckets of a period are aligned on the calendar
+// of a location and end with the bucket holding the current time:
+//
+//   - hour: 6 buckets of 10 minutes,
+//   - day: 24 buckets of an hour,
+//   - week: 7 days,
+//   - month: 30 days,
+//   - year: 12 calendar months.
+const (
+	PeriodHour  = "hour"
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+)
+
+// MaxBuckets is the most buckets of a custom range, see RangeBuckets.
+const MaxBuckets = 48
+
+var (
+	ErrUnknownPeriod = errors.New("unknown period")
+	ErrInvalidRange  = errors.New("invalid range")
+)
+
+// Bucket is the activity of a span of time.
+type Bucket struct {
+	Start time.Time
+	End   time.Time
+	// Demands is the number of demands created in the bucket and Supplies the
+	// number of contributions sent in it, to demands of any time.
+	Demands  int
+	Supplies int
+	// Ratio is the supply/demand ratio of the demands created in the bucket,
+	// 1 without any.
+	Ratio float64
+}
+
+// step is the span of a bucket, a duration under a day or a number of
+// calendar days or months.
+type step struct {
+	d      time.Duration
+	days   int
+	months int
+}
+
+// steps are the bucket spans of custom ranges, shortest first.
+var steps = []step{
+	{d: time.Minute},
+	{d: 5 * time.Minute},
+	{d: 10 * time.Minute},
+	{d: 30 * time.Minute},
+	{d: time.Hour},
+	{d: 3 * time.Hour},
+	{d: 6 * time.Hour},
+	{d: 12 * time.Hour},
+	{days: 1},
+	{days: 7},
+	{months: 1},
+	{months: 3},
+	{months: 12},
+}
+
+// start returns the start of the bucket holding t, in the location of t.
+func (s step) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch {
+	case s.months > 0:
+		return time.Date(y, time.Month((int(m)-1)/s.months*s.months+1), 1, 0, 0, 0, 0, t.Location())
+	case s.days > 0:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+	// aligned on the wall clock, the minutes overflow into the hours
+	minutes := (t.Hour()*60 + t.Minute()) / int(s.d/time.Minute) * int(s.d/time.Minute)
+	return time.Date(y, m, d, 0, minutes, 0, 0, t.Location())
+}
+
+// shift moves a bucket start by k buckets.
+func (s step) shift(t time.Time, k int) time.Time {
+	switch {
+	case s.months > 0:
+		return t.AddDate(0, k*s.months, 0)
+	case s.days > 0:
+		return t.AddDate(0, 0, k*s.days)
+	}
+	return t.Add(time.Duration(k) * s.d)
+}
+
+// count returns the number of buckets covering from to to.
+func (s step) count(from, to time.Time) int {
+	n := 0
+	for t := s.start(from); t.Before(to); t = s.shift(t, 1) {
+		n++
+	}
+	return n
+}
+
+// longest returns the longest span of a bucket.
+func (s step) longest() time.Duration {
+	switch {
+	case s.months > 0:
+		return time.Duration(s.months) * 31 * 25 * time.Hour
+	case s.days > 0:
+		return time.Duration(s.days) * 25 * time.Hour
+	}
+	return s.d
+}
+
+// periods are the steps and number of buckets of the periods.
+var periods = map[string]struct {
+	step step
+	n    int
+}{
+	PeriodHour:  {step{d: 10 * time.Minute}, 6},
+	PeriodDay:   {step{d: time.Hour}, 24},
+	PeriodWeek:  {step{days: 1}, 7},
+	PeriodMonth: {step{days: 1}, 30},
+	PeriodYear:  {step{months: 1}, 12},
+}
+
+func emptyBuckets(s step, first time.Time, n int) []Bucket {
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i] = Bucket{
+			Start: s.shift(first, i),
+			End:   s.shift(first, i+1),
+			Ratio: 1,
+		}
+	}
+	return buckets
+}
+
+// EmptyBuckets returns the buckets of a period ending with the one holding
+// now in loc, oldest first. A nil loc is UTC.
+func EmptyBuckets(period string, now time.Time, loc *time.Location) ([]Bucket, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	p, ok := periods[period]
+	if !ok {
+		return nil, ErrUnknownPeriod
+	}
+	last := p.step.start(now.In(loc))
+	return emptyBuckets(p.step, p.step.shift(last, 1-p.n), p.n), nil
+}
+
+// EmptyRangeBuckets returns the buckets covering from to to in loc, oldest
+// first. Their span is the shortest one, from a minute to a year, giving at
+// most MaxBuckets buckets, a year for longer ranges. A nil loc is UTC.
+func EmptyRangeBuckets(from, to time.Time, loc *time.Location) ([]Bucket, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidRange
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	from, to = from.In(loc), to.In(loc)
+	s := steps[len(steps)-1]
+	for _, st := range steps {
+		// too many buckets for sure, do not count them
+		if to.Sub(from)/st.longest() > MaxBuckets {
+			continue
+		}
+		if st.count(from, to) <= MaxBuckets {
+			s = st
+			break
+		}
+	}
+	return emptyBuckets(s, s.start(from), s.count(from, to)), nil
+}
+
+// Buckets aggregates the requests in the buckets of a period ending with the
+// one holding now in loc, oldest first. A nil loc is UTC.
+func Buckets(requests []Request, period string, now time.Time, loc *time.Location) ([]Bucket, error) {
+	buckets, err := EmptyBuckets(period, now, loc)
+	if err != nil {
+		return nil, err
+	}
+	return Fill(buckets, requests), nil
+}
+
+// RangeBuckets aggregates the requests in the buckets covering from to to in
+// loc, see EmptyRangeBuckets.
+func RangeBuckets(requests []Request, from, to time.Time, loc *time.Location) ([]Bucket, error) {
+	buckets, err := EmptyRangeBuckets(from, to, loc)
+	if err != nil {
+		return nil, err
+	}
+	return Fill(buckets, requests), nil
+}
+
+// index returns the index of the bucket holding t in contiguous buckets, -1
+// when none holds it.
+func index(buckets []Bucket, t time.Time) int {
+	i := sort.Search(len(buckets), func(i int) bool {
+		return buckets[i].End.After(t)
+	})
+	if i == len(buckets) || t.Before(buckets[i].Start) {
+		return -1
+	}
+	return i
+}
+
+// Fill aggregates the requests in empty contiguous buckets and returns them.
+func Fill(buckets []Bucket, requests []Request) []Bucket {
+	supplied := make([]float64, len(buckets))
+	for _, r := range requests {
+		if i := index(buckets, r.CreatedAt); i >= 0 {
+			buckets[i].Demands++
+			supplied[i] += r.Fulfilment()
+		}
+		for _, c := range r.Supplies() {
+			if i := index(buckets, c.SuppliedAt); i >= 0 {
+				buckets[i].Supplies++
+			}
+		}
+	}
+	for i := range buckets {
+		if buckets[i].Demands > 0 {
+			buckets[i].Ratio = supplied[i] / float64(buckets[i].Demands)
+		}
+	}
+	return buckets
+}
+
+// Volume is the quantity demanded and supplied in a bucket.
+type Volume struct {
+	Demanded float64
+	Supplied float64
+	// Backlog is the quantity demanded by the end of the bucket, in it or
+	// before, and not supplied by then.
+	Backlog float64
+}
+
+// Volumes sums the quantities of the requests in contiguous buckets, in unit.
+// An empty unit is the first unit seen. It returns the volume of every bucket,
+// the unit and the number of requests whose quantity could not be converted
+// into it, like Aggregate.
+func Volumes(buckets []Bucket, requests []Request, unit string) ([]Volume, string, int) {
+	if u, ok := LookupUnit(unit); ok {
+		unit = u.Symbol
+	}
+	volumes := make([]Volume, len(buckets))
+	skipped := 0
+	for _, r := range requests {
+		if unit == "" {
+			unit = r.Quantity.Normalize().Unit
+		}
+		demanded, err := ConvertAmount(r.Quantity.Amount, r.Quantity.Unit, unit)
+		if err != nil {
+			skipped++
+			continue
+		}
+		if i := index(buckets, r.CreatedAt); i >= 0 {
+			volumes[i].Demanded += demanded
+		}
+		supplies := r.Supplies()
+		for _, c := range supplies {
+			// contributions are in the unit of the demand
+			supplied, _ := ConvertAmount(c.Amount, r.Quantity.Unit, unit)
+			if i := index(buckets, c.SuppliedAt); i >= 0 {
+				volumes[i].Supplied += supplied
+			}
+		}
+		for i, b := range buckets {
+			if !r.CreatedAt.Before(b.End) || r.Fulfilled && r.FulfilledAt.Before(b.End) {
+				continue
+			}
+			backlog := demanded
+			for _, c := range supplies {
+				if c.SuppliedAt.Before(b.End) {
+					supplied, _ := ConvertAmount(c.Amount, r.Quantity.Unit, unit)
+					backlog -= supplied
+				}
+			}
+			if backlog > 0 {
+				volumes[i].Backlog += backlog
+			}
+		}
+	}
+	return volumes, unit, skipped
+}
@@ -0,0 +1,239 @@
+package economy
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	// the locations do not depend on the tzdata of the machine
+	_ "time/tzdata"
+)
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// checkContiguous checks the buckets follow each other and hold now in the
+// last one.
+func checkContiguous(t *testing.T, buckets []Bucket, n int, now time.Time) {
+	t.Helper()
+	if len(buckets) != n {
+		t.Fatalf("got %d buckets, want %d", len(buckets), n)
+	}
+	for i := 1; i < len(buckets); i++ {
+		if !buckets[i].Start.Equal(buckets[i-1].End) {
+			t.Fatalf("bucket %d starts at %v, the previous one ends at %v", i, buckets[i].Start, buckets[i-1].End)
+		}
+	}
+	last := buckets[len(buckets)-1]
+	if now.Before(last.Start) || !now.Before(last.End) {
+		t.Fatalf("last bucket %v - %v does not hold %v", last.Start, last.End, now)
+	}
+}
+
+func TestBucketsDST(t *testing.T) {
+	loc := berlin(t)
+	// clocks go from 2:00 to 3:00 on March 31, 2024
+	now := time.Date(2024, 3, 31, 12, 30, 0, 0, loc)
+
+	day, err := EmptyBuckets(PeriodDay, now, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContiguous(t, day, 24, now)
+	for _, b := range day {
+		if b.End.Sub(b.Start) != time.Hour || b.Start.Minute() != 0 {
+			t.Fatalf("bucket %v - %v is not an hour", b.Start, b.End)
+		}
+	}
+	if want := time.Date(2024, 3, 30, 12, 0, 0, 0, loc); !day[0].Start.Equal(want) {
+		t.Fatalf("day starts at %v, want %v", day[0].Start, want)
+	}
+
+	week, err := EmptyBuckets(PeriodWeek, now.AddDate(0, 0, 2), loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContiguous(t, week, 7, now.AddDate(0, 0, 2))
+	for _, b := range week {
+		if h, m, _ := b.Start.Clock(); h != 0 || m != 0 {
+			t.Fatalf("day bucket starts at %v, not at midnight", b.Start)
+		}
+		want := 24 * time.Hour
+		if b.Start.Day() == 31 {
+			want = 23 * time.Hour
+		}
+		if b.End.Sub(b.Start) != want {
+			t.Fatalf("day of %v lasts %v, want %v", b.Start, b.End.Sub(b.Start), want)
+		}
+	}
+}
+
+func TestYearBuckets(t *testing.T) {
+	loc := berlin(t)
+	now := time.Date(2024, 3, 15, 8, 0, 0, 0, loc)
+	year, err := EmptyBuckets(PeriodYear, now, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContiguous(t, year, 12, now)
+	for i, b := range year {
+		want := time.Date(2023, time.April+time.Month(i), 1, 0, 0, 0, 0, loc)
+		if !b.Start.Equal(want) {
+			t.Fatalf("month %d starts at %v, want %v", i, b.Start, want)
+		}
+	}
+	// twelve calendar months, not a year of 356 days
+	if got := year[11].End.Sub(year[0].Start); got != 366*24*time.Hour {
+		t.Fatalf("year lasts %v", got)
+	}
+}
+
+func TestCurrentBucketEdge(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	requests := []Request{
+		{ID: "1", CreatedAt: now, Quantity: Quantity{Amount: 1}},
+		{ID: "2", CreatedAt: now.Add(-time.Nanosecond), Quantity: Quantity{Amount: 1}},
+		{ID: "3", CreatedAt: now.Add(10 * time.Minute), Quantity: Quantity{Amount: 1}},
+	}
+	buckets, err := Buckets(requests, PeriodHour, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContiguous(t, buckets, 6, now)
+	// now starts the current bucket, the bucket after it is not shown
+	if !buckets[5].Start.Equal(now) {
+		t.Fatalf("current bucket starts at %v, want %v", buckets[5].Start, now)
+	}
+	if buckets[5].Demands != 1 || buckets[4].Demands != 1 {
+		t.Fatalf("got %d and %d demands in the last buckets, want 1 and 1", buckets[4].Demands, buckets[5].Demands)
+	}
+}
+
+func TestUnknownPeriod(t *testing.T) {
+	if _, err := Buckets(nil, "decade", time.Now(), nil); !errors.Is(err, ErrUnknownPeriod) {
+		t.Fatalf("got %v, want ErrUnknownPeriod", err)
+	}
+}
+
+func TestRangeBuckets(t *testing.T) {
+	loc := berlin(t)
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, loc)
+	tests := []struct {
+		to   time.Time
+		n    int
+		span time.Duration
+	}{
+		{from.Add(MaxBuckets * time.Minute), MaxBuckets, time.Minute},
+		{from.Add((MaxBuckets + 1) * time.Minute), 10, 5 * time.Minute},
+		{from.AddDate(0, 0, 2), 48, time.Hour},
+		{from.AddDate(0, 0, 30), 31, 0},
+		{from.AddDate(1, 0, 0), 13, 0},
+	}
+	for _, tt := range tests {
+		buckets, err := RangeBuckets(nil, from, tt.to, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkContiguous(t, buckets, tt.n, tt.to.Add(-time.Nanosecond))
+		if buckets[0].Start.After(from) {
+			t.Fatalf("range to %v starts at %v after %v", tt.to, buckets[0].Start, from)
+		}
+		if tt.span > 0 && buckets[0].End.Sub(buckets[0].Start) != tt.span {
+			t.Fatalf("range to %v has buckets of %v, want %v", tt.to, buckets[0].End.Sub(buckets[0].Start), tt.span)
+		}
+	}
+
+	// ranges of any length stay under the limit, but the longest ones in
+	// years
+	for _, to := range []time.Time{from.Add(time.Hour), from.AddDate(0, 0, 100), from.AddDate(3, 0, 0), from.AddDate(10, 0, 0)} {
+		buckets, err := EmptyRangeBuckets(from, to, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buckets) > MaxBuckets {
+			t.Fatalf("range to %v has %d buckets", to, len(buckets))
+		}
+	}
+	if buckets, _ := EmptyRangeBuckets(from, from.AddDate(100, 0, 0), loc); len(buckets) != 101 {
+		t.Fatalf("got %d buckets for a century, want a year each", len(buckets))
+	}
+
+	if _, err := EmptyRangeBuckets(from, from, loc); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("got %v, want ErrInvalidRange", err)
+	}
+}
+
+func TestFill(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	buckets, err := EmptyBuckets(PeriodDay, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []Request{
+		{ID: "1", CreatedAt: now.Add(-2 * time.Hour), Quantity: Quantity{Amount: 10},
+			Contributions: []Contribution{{Supplier: "bob", Amount: 5, SuppliedAt: now.Add(-90 * time.Minute)}, {Supplier: "carol", Amount: 5, SuppliedAt: now}},
+			Fulfilled:     true, FulfilledAt: now},
+		{ID: "2", CreatedAt: now.Add(-2 * time.Hour), Quantity: Quantity{Amount: 10}},
+		// before the period, supplied in it
+		{ID: "3", CreatedAt: now.AddDate(0, 0, -3), Quantity: Quantity{Amount: 4},
+			Contributions: []Contribution{{Supplier: "bob", Amount: 1, SuppliedAt: now.Add(-2 * time.Hour)}}},
+	}
+	Fill(buckets, requests)
+
+	want := map[int]Bucket{
+		21: {Demands: 2, Supplies: 2, Ratio: 0.5},
+		23: {Supplies: 1, Ratio: 1},
+	}
+	for i, b := range buckets {
+		w, ok := want[i]
+		if !ok {
+			w = Bucket{Ratio: 1}
+		}
+		if b.Demands != w.Demands || b.Supplies != w.Supplies || b.Ratio != w.Ratio {
+			t.Errorf("bucket %d: got %d demands, %d supplies and ratio %v, want %d, %d and %v", i, b.Demands, b.Supplies, b.Ratio, w.Demands, w.Supplies, w.Ratio)
+		}
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	buckets, err := EmptyBuckets(PeriodDay, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []Request{
+		{ID: "1", CreatedAt: now.Add(-3 * time.Hour), Quantity: Quantity{Amount: 1, Unit: "t"},
+			Contributions: []Contribution{{Supplier: "bob", Amount: 0.25, SuppliedAt: now.Add(-2 * time.Hour)}}},
+		{ID: "2", CreatedAt: now.Add(-2 * time.Hour), Quantity: Quantity{Amount: 500, Unit: "kg"},
+			Contributions: []Contribution{{Supplier: "carol", Amount: 500, SuppliedAt: now.Add(-time.Hour)}},
+			Fulfilled:     true, FulfilledAt: now.Add(-time.Hour)},
+		{ID: "3", CreatedAt: now.Add(-time.Hour), Quantity: Quantity{Amount: 10, Unit: "litres"}},
+	}
+	volumes, unit, skipped := Volumes(buckets, requests, "kg")
+	if unit != "kg" || skipped != 1 {
+		t.Fatalf("got unit %q and %d skipped, want kg and 1", unit, skipped)
+	}
+	want := map[int]Volume{
+		20: {Demanded: 1000, Backlog: 1000},
+		21: {Demanded: 500, Supplied: 250, Backlog: 1250},
+		22: {Supplied: 500, Backlog: 750},
+		23: {Backlog: 750},
+	}
+	for i, v := range volumes {
+		w := want[i]
+		if math.Abs(v.Demanded-w.Demanded) > 1e-9 || math.Abs(v.Supplied-w.Supplied) > 1e-9 || math.Abs(v.Backlog-w.Backlog) > 1e-9 {
+			t.Errorf("bucket %d: got %+v, want %+v", i, v, w)
+		}
+	}
+
+	// the unit of the first request by default
+	if _, unit, _ := Volumes(buckets, requests, ""); unit != "t" {
+		t.Fatalf("got unit %q, want t", unit)
+	}
+}
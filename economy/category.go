@@ -0,0 +1,221 @@
+package economy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Category is a node of the category taxonomy, e.g. rice under grain under
+// food.
+type Category struct {
+	// ID is the lower case key of the category in requests and offers.
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Parent string `json:"parent,omitempty"`
+	// Unit is the default unit of the quantities of the category, inherited
+	// by subcategories without their own.
+	Unit string `json:"unit,omitempty"`
+	// Icon is the Font Awesome icon of the category, e.g. "fa-droplet".
+	Icon string `json:"icon,omitempty"`
+}
+
+// Taxonomy is the registry of categories, a forest of top level categories
+// and their subcategories in declaration order. The zero value is an empty
+// taxonomy.
+type Taxonomy struct {
+	categories map[string]Category
+	children   map[string][]string
+}
+
+// NewTaxonomy builds a taxonomy from categories listed parents first.
+func NewTaxonomy(categories []Category) (*Taxonomy, error) {
+	t := &Taxonomy{}
+	for _, c := range categories {
+		if err := t.Add(c); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// DefaultTaxonomy returns the categories of the game when none is configured.
+func DefaultTaxonomy() *Taxonomy {
+	t, err := NewTaxonomy([]Category{
+		{ID: "water", Name: "Water", Unit: "litres", Icon: "fa-droplet"},
+		{ID: "food", Name: "Food", Unit: "kg", Icon: "fa-wheat-awn"},
+		{ID: "grain", Name: "Grain", Parent: "food"},
+		{ID: "rice", Name: "Rice", Parent: "grain"},
+		{ID: "housing", Name: "Housing", Unit: "person-nights", Icon: "fa-house"},
+		{ID: "other", Name: "Other", Icon: "fa-box"},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// ParseTaxonomy decodes a taxonomy document, see MarshalJSON.
+func ParseTaxonomy(b []byte) (*Taxonomy, error) {
+	doc := struct {
+		Categories []Category `json:"categories"`
+	}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return NewTaxonomy(doc.Categories)
+}
+
+// MarshalJSON encodes the taxonomy as a document listing its categories
+// parents first.
+func (t *Taxonomy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Categories []Category `json:"categories"`
+	}{t.All()})
+}
+
+// Add registers a category under an existing parent.
+func (t *Taxonomy) Add(c Category) error {
+	c.ID = strings.ToLower(strings.TrimSpace(c.ID))
+	c.Parent = strings.ToLower(strings.TrimSpace(c.Parent))
+	if c.ID == "" || c.ID == CategoryAll {
+		return fmt.Errorf("category: invalid id %q", c.ID)
+	}
+	if _, ok := t.categories[c.ID]; ok {
+		return fmt.Errorf("category: duplicate id %q", c.ID)
+	}
+	if c.Parent != "" {
+		if _, ok := t.categories[c.Parent]; !ok {
+			return fmt.Errorf("category: unknown parent %q of %q", c.Parent, c.ID)
+		}
+	}
+	if c.Name == "" {
+		c.Name = strings.Title(c.ID)
+	}
+	if t.categories == nil {
+		t.categories = make(map[string]Category)
+		t.children = make(map[string][]string)
+	}
+	t.categories[c.ID] = c
+	t.children[c.Parent] = append(t.children[c.Parent], c.ID)
+	return nil
+}
+
+// Get returns a category, the lookup is case insensitive.
+func (t *Taxonomy) Get(id string) (Category, bool) {
+	c, ok := t.categories[strings.ToLower(id)]
+	return c, ok
+}
+
+// Roots returns the top level categories.
+func (t *Taxonomy) Roots() []Category {
+	return t.Children("")
+}
+
+// Children returns the direct subcategories of a category.
+func (t *Taxonomy) Children(id string) []Category {
+	res := []Category{}
+	for _, child := range t.children[strings.ToLower(id)] {
+		res = append(res, t.categories[child])
+	}
+	return res
+}
+
+// All returns every category depth first, each parent before its children.
+func (t *Taxonomy) All() []Category {
+	res := []Category{}
+	var walk func(id string)
+	walk = func(id string) {
+		for _, child := range t.children[id] {
+			res = append(res, t.categories[child])
+			walk(child)
+		}
+	}
+	walk("")
+	return res
+}
+
+// Path returns the ancestors of a category from its top level category down
+// to the category itself, only the category for unknown ones.
+func (t *Taxonomy) Path(id string) []string {
+	id = strings.ToLower(id)
+	path := []string{id}
+	for c, ok := t.categories[id]; ok && c.Parent != ""; c, ok = t.categories[c.Parent] {
+		path = append([]string{c.Parent}, path...)
+	}
+	return path
+}
+
+// Root returns the top level category of a category.
+func (t *Taxonomy) Root(id string) string {
+	return t.Path(id)[0]
+}
+
+// Depth returns the number of ancestors of a category.
+func (t *Taxonomy) Depth(id string) int {
+	return len(t.Path(id)) - 1
+}
+
+// Contains reports whether the category id is ancestor or one of its
+// descendants.
+func (t *Taxonomy) Contains(ancestor, id string) bool {
+	ancestor = strings.ToLower(ancestor)
+	for _, a := range t.Path(id) {
+		if a == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand returns the categories with all their descendants.
+func (t *Taxonomy) Expand(categories []string) []string {
+	res := []string{}
+	seen := make(map[string]bool)
+	var walk func(id string)
+	walk = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		res = append(res, id)
+		for _, child := range t.children[id] {
+			walk(child)
+		}
+	}
+	for _, c := range categories {
+		walk(strings.ToLower(c))
+	}
+	return res
+}
+
+// Unit returns the default unit of a category, inherited from the closest
+// ancestor with one.
+func (t *Taxonomy) Unit(id string) string {
+	path := t.Path(id)
+	for i := len(path) - 1; i >= 0; i-- {
+		if u := t.categories[path[i]].Unit; u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// Icon returns the icon of a category, inherited like Unit.
+func (t *Taxonomy) Icon(id string) string {
+	path := t.Path(id)
+	for i := len(path) - 1; i >= 0; i-- {
+		if icon := t.categories[path[i]].Icon; icon != "" {
+			return icon
+		}
+	}
+	return ""
+}
+
+// Name returns the display name of a category, the ID for unknown ones.
+func (t *Taxonomy) Name(id string) string {
+	if c, ok := t.Get(id); ok {
+		return c.Name
+	}
+	return id
+}
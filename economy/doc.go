@@ -0,0 +1,7 @@
+// Package economy is the economic model of Cyber Stasis: demands and their
+// partial supplies, standing offers, the matching of suppliers to demands,
+// supply/demand ratios, shortages and the reputation of citizens.
+//
+// It is free of any UI, storage or network dependency so the same rules drive
+// the dashboard and run natively, e.g. in simulations.
+package economy
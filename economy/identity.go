@@ -0,0 +1,72 @@
+package economy
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Citizens are pseudonymous: the ID of a citizen is the handle of its signing
+// key, so only the holder of the key can sign in its name. A citizen moving to
+// a new key publishes a Rotation linking its old handle to the new one and
+// keeps its history.
+
+var ErrBadRotation = errors.New("invalid key rotation")
+
+var handleEncoding = base32.NewEncoding(crockford).WithPadding(base32.NoPadding)
+
+// Handle returns the public handle of a key, the first 80 bits of its hash in
+// 16 characters.
+func Handle(key ed25519.PublicKey) string {
+	h := sha256.Sum256(key)
+	return handleEncoding.EncodeToString(h[:10])
+}
+
+// Rotation links the old handle of a citizen to the handle of its new key. It
+// is signed by both keys.
+type Rotation struct {
+	From         string
+	To           string
+	FromKey      []byte
+	ToKey        []byte
+	RotatedAt    time.Time
+	Signature    []byte
+	NewSignature []byte
+}
+
+func rotationMessage(from, to string, at time.Time) []byte {
+	return []byte("rotate\n" + from + "\n" + to + "\n" + at.UTC().Format(time.RFC3339Nano))
+}
+
+// NewRotation links the handle of key old to the handle of key new.
+func NewRotation(old, new ed25519.PrivateKey, at time.Time) Rotation {
+	r := Rotation{
+		FromKey:   old.Public().(ed25519.PublicKey),
+		ToKey:     new.Public().(ed25519.PublicKey),
+		RotatedAt: at,
+	}
+	r.From = Handle(r.FromKey)
+	r.To = Handle(r.ToKey)
+	msg := rotationMessage(r.From, r.To, r.RotatedAt)
+	r.Signature = ed25519.Sign(old, msg)
+	r.NewSignature = ed25519.Sign(new, msg)
+	return r
+}
+
+// Verify checks the handles and both signatures of the rotation.
+func (r Rotation) Verify() error {
+	if len(r.FromKey) != ed25519.PublicKeySize || len(r.ToKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: %s", ErrBadRotation, r.From)
+	}
+	if Handle(r.FromKey) != r.From || Handle(r.ToKey) != r.To || r.From == r.To {
+		return fmt.Errorf("%w: %s", ErrBadRotation, r.From)
+	}
+	msg := rotationMessage(r.From, r.To, r.RotatedAt)
+	if !ed25519.Verify(r.FromKey, msg, r.Signature) || !ed25519.Verify(r.ToKey, msg, r.NewSignature) {
+		return fmt.Errorf("%w: %s", ErrBadRotation, r.From)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package economy
+
+import (
+	"sort"
+	"strings"
+)
+
+// CategoryAll selects the requests of every category.
+const CategoryAll = "all"
+
+// Ledger is the set of demand records known to a citizen, ordered by request
+// ID, which sorts by creation time, and bucketed by category. With a taxonomy
+// the bucket of a category also holds the demands of its subcategories.
+type Ledger struct {
+	requests   map[string]Request
+	index      []string
+	categories map[string][]string
+	taxonomy   *Taxonomy
+}
+
+// NewLedger returns an empty ledger.
+func NewLedger() *Ledger {
+	return &Ledger{
+		requests:   make(map[string]Request),
+		categories: make(map[string][]string),
+	}
+}
+
+// Apply stores a demand record unless a newer version is already known. It
+// reports whether the record was applied.
+func (l *Ledger) Apply(r Request) bool {
+	if cur, ok := l.requests[r.ID]; ok && cur.Version > r.Version {
+		return false
+	}
+	l.requests[r.ID] = r
+	l.index = InsertID(l.index, r.ID)
+	for _, cat := range l.path(r.Category) {
+		l.categories[cat] = InsertID(l.categories[cat], r.ID)
+	}
+	return true
+}
+
+// Replace stores a demand record whatever the version known, e.g. to go back
+// to the stored record when a newer one could not be stored.
+func (l *Ledger) Replace(r Request) {
+	delete(l.requests, r.ID)
+	l.Apply(r)
+}
+
+// SetTaxonomy buckets the demands by the categories of t from now on.
+func (l *Ledger) SetTaxonomy(t *Taxonomy) {
+	l.taxonomy = t
+	l.categories = make(map[string][]string)
+	for _, id := range l.index {
+		for _, cat := range l.path(l.requests[id].Category) {
+			l.categories[cat] = append(l.categories[cat], id)
+		}
+	}
+}
+
+func (l *Ledger) path(category string) []string {
+	if l.taxonomy == nil {
+		return []string{strings.ToLower(category)}
+	}
+	return l.taxonomy.Path(category)
+}
+
+// Request returns the record of a demand, the zero Request when unknown.
+func (l *Ledger) Request(id string) Request {
+	return l.requests[id]
+}
+
+// Has reports whether the demand is known.
+func (l *Ledger) Has(id string) bool {
+	_, ok := l.requests[id]
+	return ok
+}
+
+// Len returns the number of demands.
+func (l *Ledger) Len() int {
+	return len(l.index)
+}
+
+// IDs returns the IDs of all the demands, oldest first. The slice must not be
+// modified.
+func (l *Ledger) IDs() []string {
+	return l.index
+}
+
+// Requests returns all the demands, oldest first.
+func (l *Ledger) Requests() []Request {
+	res := make([]Request, 0, len(l.index))
+	for _, id := range l.index {
+		res = append(res, l.requests[id])
+	}
+	return res
+}
+
+// Signed returns the demands but the legacy ones, oldest first.
+func (l *Ledger) Signed() []Request {
+	res := make([]Request, 0, len(l.index))
+	for _, id := range l.index {
+		if !l.requests[id].Legacy {
+			res = append(res, l.requests[id])
+		}
+	}
+	return res
+}
+
+// Category returns the IDs of the demands of a category, oldest first. The
+// category is case insensitive and CategoryAll selects every demand. The slice
+// must not be modified.
+func (l *Ledger) Category(category string) []string {
+	cat := strings.ToLower(category)
+	if cat == CategoryAll {
+		return l.index
+	}
+	return l.categories[cat]
+}
+
+// Total aggregates the demands of a category and its subcategories in the
+// unit of the category.
+func (l *Ledger) Total(category string) Total {
+	cat := strings.ToLower(category)
+	ids := l.Category(cat)
+	rs := make([]Request, 0, len(ids))
+	for _, id := range ids {
+		rs = append(rs, l.requests[id])
+	}
+	unit := ""
+	if l.taxonomy != nil && cat != CategoryAll {
+		unit = l.taxonomy.Unit(cat)
+	}
+	return Aggregate(cat, unit, rs)
+}
+
+// Latest reports whether the demand is the newest of its category and its
+// subcategories.
+func (l *Ledger) Latest(id string) bool {
+	r, ok := l.requests[id]
+	if !ok {
+		return false
+	}
+	ids := l.categories[strings.ToLower(r.Category)]
+	return len(ids) > 0 && ids[len(ids)-1] == id
+}
+
+// Pending reports whether any demand still waits for supplies.
+func (l *Ledger) Pending() bool {
+	for _, r := range l.requests {
+		if !r.Fulfilled && !r.Legacy {
+			return true
+		}
+	}
+	return false
+}
+
+// InsertID returns the sorted ids with id added unless it is already there.
+// The given slice is never modified as views of the ledger may share it.
+func InsertID(ids []string, id string) []string {
+	i := sort.SearchStrings(ids, id)
+	if i < len(ids) && ids[i] == id {
+		return ids
+	}
+	res := make([]string, 0, len(ids)+1)
+	res = append(res, ids[:i]...)
+	res = append(res, id)
+	return append(res, ids[i:]...)
+}
@@ -0,0 +1,77 @@
+package economy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLedgerApply(t *testing.T) {
+	l := NewLedger()
+	l.SetTaxonomy(DefaultTaxonomy())
+
+	// IDs sort by creation time whatever the order they arrive in
+	for _, r := range []Request{
+		{ID: "02", Category: "rice", Version: 0},
+		{ID: "01", Category: "water", Version: 0},
+		{ID: "03", Category: "Food", Version: 0},
+	} {
+		if !l.Apply(r) {
+			t.Fatalf("%s not applied", r.ID)
+		}
+	}
+	if got, want := l.IDs(), []string{"01", "02", "03"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("IDs: got %v, want %v", got, want)
+	}
+	// a category holds the demands of its subcategories
+	if got, want := l.Category("food"), []string{"02", "03"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("food: got %v, want %v", got, want)
+	}
+	if got, want := l.Category(CategoryAll), []string{"01", "02", "03"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("all: got %v, want %v", got, want)
+	}
+
+	// newer versions replace the record, older ones are ignored
+	if !l.Apply(Request{ID: "02", Category: "rice", Version: 2, Details: "v2"}) {
+		t.Fatal("newer version not applied")
+	}
+	if l.Apply(Request{ID: "02", Category: "rice", Version: 1, Details: "v1"}) {
+		t.Fatal("older version applied")
+	}
+	if got := l.Request("02").Details; got != "v2" {
+		t.Fatalf("details: got %q, want v2", got)
+	}
+	// the same version is applied again without duplicating the indexes
+	if !l.Apply(Request{ID: "02", Category: "rice", Version: 2, Details: "v2"}) {
+		t.Fatal("same version not applied")
+	}
+	if l.Len() != 3 || len(l.Category("rice")) != 1 {
+		t.Fatalf("indexes: got %v and %v", l.IDs(), l.Category("rice"))
+	}
+
+	// Replace goes back to an older record
+	l.Replace(Request{ID: "02", Category: "rice", Version: 1, Details: "v1"})
+	if got := l.Request("02"); got.Version != 1 || got.Details != "v1" {
+		t.Fatalf("replace: got %+v", got)
+	}
+}
+
+func TestLedgerLegacy(t *testing.T) {
+	l := NewLedger()
+	l.Apply(Request{ID: "01", Category: "water", Fulfilled: true})
+	l.Apply(Request{ID: "02", Category: "water", Legacy: true})
+
+	// legacy demands are kept for the charts but left out of the market
+	if got, want := l.Category(CategoryAll), []string{"01", "02"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("all: got %v, want %v", got, want)
+	}
+	if got := l.Signed(); len(got) != 1 || got[0].ID != "01" {
+		t.Fatalf("signed: got %+v", got)
+	}
+	if l.Pending() {
+		t.Fatal("legacy demand pending")
+	}
+	l.Apply(Request{ID: "03", Category: "water"})
+	if !l.Pending() {
+		t.Fatal("demand not pending")
+	}
+}
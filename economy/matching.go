@@ -0,0 +1,144 @@
+package economy
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Weights of the components of a match score, they add up to 1.
+const (
+	weightQuantity = 0.4
+	weightDistance = 0.4
+	weightWaiting  = 0.2
+)
+
+// GeoPoint is a position in decimal degrees.
+type GeoPoint struct {
+	Lat float64
+	Lon float64
+}
+
+// DistanceKm returns the great-circle distance between two points.
+func DistanceKm(a, b GeoPoint) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// Capabilities declares what a supplier is able to provide.
+type Capabilities struct {
+	// Categories the supplier provides, all of them when empty.
+	Categories []string
+	// MinQuantity and MaxQuantity bound the amount supplied at once, 0 is
+	// unbounded.
+	MinQuantity float64
+	MaxQuantity float64
+	// Location and RadiusKm bound the area served, unbounded without a
+	// location or with a radius of 0.
+	Location *GeoPoint
+	RadiusKm float64
+	// AvailableFrom and AvailableUntil bound the period the supplier is
+	// available in, the zero times are unbounded.
+	AvailableFrom  time.Time
+	AvailableUntil time.Time
+}
+
+// Provides reports whether the supplier provides the category.
+func (c Capabilities) Provides(category string) bool {
+	if len(c.Categories) == 0 {
+		return true
+	}
+	for _, cat := range c.Categories {
+		if strings.EqualFold(cat, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggestion is a pending demand scored against the capabilities of a
+// supplier.
+type Suggestion struct {
+	RequestID string
+	// Score is between 0 and 1, the higher the better.
+	Score float64
+	// Amount is how much of the demand the supplier can provide.
+	Amount float64
+	// DistanceKm is negative when either side has no location.
+	DistanceKm float64
+}
+
+// ScoreDemand scores a pending demand against the capabilities of a supplier.
+// It reports false when the supplier cannot serve the demand at all.
+//
+// The score weighs how much of the remaining quantity the supplier covers, how
+// close the requester is within the served radius and how long the demand has
+// been waiting, so that full, nearby and old demands come first.
+func ScoreDemand(c Capabilities, r Request, now time.Time) (Suggestion, bool) {
+	s := Suggestion{RequestID: r.ID, DistanceKm: -1}
+	if r.Fulfilled || !c.Provides(r.Category) {
+		return s, false
+	}
+	if !c.AvailableUntil.IsZero() && (now.After(c.AvailableUntil) || r.CreatedAt.After(c.AvailableUntil)) {
+		return s, false
+	}
+	if !c.AvailableFrom.IsZero() && now.Before(c.AvailableFrom) {
+		return s, false
+	}
+
+	remaining := r.Remaining()
+	if remaining <= 0 || (c.MinQuantity > 0 && remaining < c.MinQuantity) {
+		return s, false
+	}
+	s.Amount = remaining
+	if c.MaxQuantity > 0 && remaining > c.MaxQuantity {
+		s.Amount = c.MaxQuantity
+	}
+	quantityScore := s.Amount / remaining
+
+	// demands without a location are neither preferred nor excluded
+	distanceScore := 0.5
+	if c.Location != nil && r.Location != nil {
+		s.DistanceKm = DistanceKm(*c.Location, *r.Location)
+		if c.RadiusKm > 0 {
+			if s.DistanceKm > c.RadiusKm {
+				return s, false
+			}
+			distanceScore = 1 - s.DistanceKm/c.RadiusKm
+		}
+	}
+
+	// approaches 1 as the demand waits, 0.63 after a day
+	waitingScore := 1 - math.Exp(-now.Sub(r.CreatedAt).Hours()/24)
+	if waitingScore < 0 {
+		waitingScore = 0
+	}
+
+	s.Score = weightQuantity*quantityScore + weightDistance*distanceScore + weightWaiting*waitingScore
+	return s, true
+}
+
+// RankMatches returns the demands the supplier can serve, best first.
+func RankMatches(c Capabilities, requests []Request, supplierID string, now time.Time) []Suggestion {
+	res := []Suggestion{}
+	for _, r := range requests {
+		if r.CitizenID == supplierID {
+			continue
+		}
+		if s, ok := ScoreDemand(c, r, now); ok {
+			res = append(res, s)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].RequestID < res[j].RequestID
+	})
+	return res
+}
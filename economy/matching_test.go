@@ -0,0 +1,90 @@
+package economy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScoreDemand(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	paris := GeoPoint{Lat: 48.8566, Lon: 2.3522}
+	versailles := GeoPoint{Lat: 48.8049, Lon: 2.1204}
+	lyon := GeoPoint{Lat: 45.764, Lon: 4.8357}
+	demand := Request{ID: "d", CitizenID: "alice", Category: "water", Quantity: Quantity{Amount: 100, Unit: "litres"}, CreatedAt: now.Add(-24 * time.Hour), Location: &versailles}
+
+	tests := []struct {
+		name   string
+		c      Capabilities
+		r      Request
+		ok     bool
+		amount float64
+		score  float64
+	}{
+		{
+			name:   "anything anywhere",
+			c:      Capabilities{},
+			r:      demand,
+			ok:     true,
+			amount: 100,
+			// full quantity, no distance preference, waited a day
+			score: 0.4 + 0.4*0.5 + 0.2*(1-math.Exp(-1)),
+		},
+		{
+			name:   "capped by the maximum",
+			c:      Capabilities{MaxQuantity: 25},
+			r:      demand,
+			ok:     true,
+			amount: 25,
+			score:  0.4*0.25 + 0.4*0.5 + 0.2*(1-math.Exp(-1)),
+		},
+		{
+			name:   "within the radius",
+			c:      Capabilities{Location: &paris, RadiusKm: 50},
+			r:      demand,
+			ok:     true,
+			amount: 100,
+			score:  0.4 + 0.4*(1-DistanceKm(paris, versailles)/50) + 0.2*(1-math.Exp(-1)),
+		},
+		{name: "out of the radius", c: Capabilities{Location: &lyon, RadiusKm: 50}, r: demand},
+		{name: "other category", c: Capabilities{Categories: []string{"food"}}, r: demand},
+		{name: "below the minimum", c: Capabilities{MinQuantity: 200}, r: demand},
+		{name: "not available yet", c: Capabilities{AvailableFrom: now.Add(time.Hour)}, r: demand},
+		{name: "no longer available", c: Capabilities{AvailableUntil: now.Add(-time.Hour)}, r: demand},
+		{name: "fulfilled", c: Capabilities{}, r: Request{ID: "d", Category: "water", Quantity: Quantity{Amount: 1}, Fulfilled: true}},
+	}
+	for _, tt := range tests {
+		s, ok := ScoreDemand(tt.c, tt.r, now)
+		if ok != tt.ok {
+			t.Errorf("%s: got ok %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if s.Amount != tt.amount || math.Abs(s.Score-tt.score) > 1e-9 {
+			t.Errorf("%s: got amount %v score %v, want %v and %v", tt.name, s.Amount, s.Score, tt.amount, tt.score)
+		}
+	}
+}
+
+func TestRankMatches(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	requests := []Request{
+		{ID: "recent", CitizenID: "alice", Category: "water", Quantity: Quantity{Amount: 10}, CreatedAt: now.Add(-time.Hour)},
+		{ID: "old", CitizenID: "bob", Category: "water", Quantity: Quantity{Amount: 10}, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "own", CitizenID: "carol", Category: "water", Quantity: Quantity{Amount: 10}, CreatedAt: now.Add(-72 * time.Hour)},
+		{ID: "food", CitizenID: "bob", Category: "food", Quantity: Quantity{Amount: 10}, CreatedAt: now.Add(-72 * time.Hour)},
+		{ID: "same", CitizenID: "dave", Category: "water", Quantity: Quantity{Amount: 10}, CreatedAt: now.Add(-time.Hour)},
+	}
+	got := RankMatches(Capabilities{Categories: []string{"Water"}}, requests, "carol", now)
+	want := []string{"old", "recent", "same"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d suggestions, want %d", len(got), len(want))
+	}
+	for i, s := range got {
+		if s.RequestID != want[i] {
+			t.Errorf("rank %d: got %s, want %s", i, s.RequestID, want[i])
+		}
+	}
+}
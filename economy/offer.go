@@ -0,0 +1,107 @@
+package economy
+
+import (
+	"strings"
+	"time"
+)
+
+// Offer is a standing offer of a depot or citizen, published independently of
+// any demand, e.g. 200 kg of grain at depot X until Friday.
+type Offer struct {
+	ID        string
+	CitizenID string
+	Category  string
+	Quantity  Quantity
+	Location  string
+	Details   string
+	// AvailableUntil is the end of the offer, the zero time never expires.
+	AvailableUntil time.Time
+	CreatedAt      time.Time
+	Matches        []OfferMatch
+	// Version is bumped by the citizen on every update of the offer
+	Version int
+	// Signer is the public key of the citizen who signed the offer.
+	Signer    []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+}
+
+// OfferMatch is a demand supplied from an offer.
+type OfferMatch struct {
+	RequestID string
+	Amount    float64
+	MatchedAt time.Time
+}
+
+// Remaining returns the amount still available in the offer.
+func (o Offer) Remaining() float64 {
+	r := o.Quantity.Amount
+	for _, m := range o.Matches {
+		r -= m.Amount
+	}
+	if r < 1e-9 {
+		return 0
+	}
+	return r
+}
+
+// Open reports whether something can still be supplied from the offer.
+func (o Offer) Open(now time.Time) bool {
+	if !o.AvailableUntil.IsZero() && now.After(o.AvailableUntil) {
+		return false
+	}
+	return o.Remaining() > 0
+}
+
+// Matched reports whether the offer has already supplied the demand.
+func (o Offer) Matched(requestID string) bool {
+	for _, m := range o.Matches {
+		if m.RequestID == requestID {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAmount returns how much of demand r the offer can supply given the
+// amount already reserved by pending claims, 0 when they do not match. An
+// offer matches the demands of its category and of its subcategories in t,
+// t may be nil. The reserved amount is in the unit of the offer, the result
+// in the unit of the demand.
+func MatchAmount(o Offer, reserved float64, r Request, t *Taxonomy, now time.Time) float64 {
+	if !o.Open(now) || r.Fulfilled || r.Legacy || r.CitizenID == o.CitizenID {
+		return 0
+	}
+	if !offers(t, o.Category, r.Category) {
+		return 0
+	}
+	if o.Matched(r.ID) {
+		return 0
+	}
+	// the amount is in the unit of the demand
+	amount, err := ConvertAmount(o.Remaining()-reserved, o.Quantity.Unit, r.Quantity.Unit)
+	if err != nil {
+		return 0
+	}
+	if r.Remaining() < amount {
+		amount = r.Remaining()
+	}
+	if amount < 0 {
+		return 0
+	}
+	return amount
+}
+
+// offers reports whether an offer of category covers a demand of category
+// demanded, through the same expansion as Capabilities.
+func offers(t *Taxonomy, category, demanded string) bool {
+	categories := []string{category}
+	if t != nil {
+		categories = t.Expand(categories)
+	}
+	for _, c := range categories {
+		if strings.EqualFold(c, demanded) {
+			return true
+		}
+	}
+	return false
+}
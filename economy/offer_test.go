@@ -0,0 +1,75 @@
+package economy
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func TestMatchAmount(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	tax := DefaultTaxonomy()
+	offer := Offer{ID: "o", CitizenID: "depot", Category: "food", Quantity: Quantity{Amount: 200, Unit: "kg"}}
+	demand := func(category string, amount float64, unit string) Request {
+		return Request{ID: category, CitizenID: "alice", Category: category, Quantity: Quantity{Amount: amount, Unit: unit}}
+	}
+
+	tests := []struct {
+		name     string
+		offer    Offer
+		reserved float64
+		demand   Request
+		taxonomy *Taxonomy
+		want     float64
+	}{
+		{"same category", offer, 0, demand("food", 5, "kg"), tax, 5},
+		{"subcategory", offer, 0, demand("rice", 5, "kg"), tax, 5},
+		{"subcategory without taxonomy", offer, 0, demand("rice", 5, "kg"), nil, 0},
+		{"parent category", Offer{ID: "o", CitizenID: "depot", Category: "rice", Quantity: Quantity{Amount: 200, Unit: "kg"}}, 0, demand("food", 5, "kg"), tax, 0},
+		{"other category", offer, 0, demand("water", 5, "litres"), tax, 0},
+		{"converted", offer, 0, demand("rice", 2000, "g"), tax, 2000},
+		{"capped by the offer", offer, 199, demand("food", 5, "kg"), tax, 1},
+		{"incompatible units", offer, 0, demand("food", 5, "litres"), tax, 0},
+		{"legacy demand", offer, 0, Request{ID: "d", CitizenID: "alice", Category: "food", Quantity: Quantity{Amount: 5, Unit: "kg"}, Legacy: true}, tax, 0},
+		{"own demand", offer, 0, Request{ID: "d", CitizenID: "depot", Category: "food", Quantity: Quantity{Amount: 5, Unit: "kg"}}, tax, 0},
+		{"expired", Offer{ID: "o", CitizenID: "depot", Category: "food", Quantity: Quantity{Amount: 200, Unit: "kg"}, AvailableUntil: now.Add(-time.Hour)}, 0, demand("food", 5, "kg"), tax, 0},
+	}
+	for _, tt := range tests {
+		if got := MatchAmount(tt.offer, tt.reserved, tt.demand, tt.taxonomy, now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSignedOffer(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := NewKeyring()
+	o := Offer{
+		ID:        "01HR",
+		CitizenID: Handle(key.Public().(ed25519.PublicKey)),
+		Category:  "grain",
+		Quantity:  Quantity{Amount: 1, Unit: "t"},
+		CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := o.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Verify(k); err != nil {
+		t.Fatal(err)
+	}
+
+	matched := o
+	matched.Matches = []OfferMatch{{RequestID: "01HQ", Amount: 500}}
+	if matched.Verify(k) == nil {
+		t.Fatal("offer matched by another citizen verified")
+	}
+	if err := matched.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := matched.Verify(k); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,161 @@
+package economy
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A proposed category is adopted once ProposalQuorum distinct citizens,
+// including the proposer, endorse it within ProposalWindow of the proposal.
+const (
+	ProposalQuorum = 3
+	ProposalWindow = 72 * time.Hour
+)
+
+// Statuses of a CategoryProposal.
+const (
+	ProposalPending = "pending"
+	ProposalAdopted = "adopted"
+	ProposalExpired = "expired"
+)
+
+// CategoryProposal is a category proposed by a citizen for the taxonomy.
+type CategoryProposal struct {
+	ID           string
+	Category     Category
+	ProposedBy   string
+	ProposedAt   time.Time
+	Endorsements []Endorsement
+	Status       string
+	DecidedAt    time.Time `json:",omitempty"`
+	// Signer is the public key of the proposer, who signs the proposal
+	// without its endorsements and outcome.
+	Signer    []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+}
+
+// Endorsement is the vote of a citizen for a proposal, signed with its key.
+type Endorsement struct {
+	CitizenID  string
+	EndorsedAt time.Time
+	Signer     []byte `json:",omitempty"`
+	Signature  []byte `json:",omitempty"`
+}
+
+func endorsementMessage(proposalID, citizenID string, at time.Time) []byte {
+	return []byte("endorse\n" + proposalID + "\n" + citizenID + "\n" + at.UTC().Format(time.RFC3339Nano))
+}
+
+// NewEndorsement returns the vote of the holder of key for a proposal.
+func NewEndorsement(key ed25519.PrivateKey, proposalID string, at time.Time) Endorsement {
+	e := Endorsement{
+		EndorsedAt: at,
+		Signer:     key.Public().(ed25519.PublicKey),
+	}
+	e.CitizenID = Handle(e.Signer)
+	e.Signature = ed25519.Sign(key, endorsementMessage(proposalID, e.CitizenID, e.EndorsedAt))
+	return e
+}
+
+// Verify checks that the citizen signed the vote for the proposal with its
+// own key.
+func (e Endorsement) Verify(proposalID string, k *Keyring) error {
+	if len(e.Signer) != ed25519.PublicKeySize || len(e.Signature) == 0 {
+		return fmt.Errorf("%w: endorsement of %s", ErrUnsigned, e.CitizenID)
+	}
+	if !ed25519.Verify(e.Signer, endorsementMessage(proposalID, e.CitizenID, e.EndorsedAt), e.Signature) {
+		return fmt.Errorf("%w: endorsement of %s", ErrBadSignature, e.CitizenID)
+	}
+	return k.Check(e.CitizenID, e.Signer)
+}
+
+// NewCategoryProposal returns a pending proposal. The proposer endorses it
+// like any other citizen.
+func NewCategoryProposal(id string, c Category, citizenID string, at time.Time) CategoryProposal {
+	return CategoryProposal{
+		ID:         id,
+		Category:   c,
+		ProposedBy: citizenID,
+		ProposedAt: at,
+		Status:     ProposalPending,
+	}
+}
+
+// Endorsed reports whether the citizen has endorsed the proposal.
+func (p CategoryProposal) Endorsed(citizenID string) bool {
+	for _, e := range p.Endorsements {
+		if e.CitizenID == citizenID {
+			return true
+		}
+	}
+	return false
+}
+
+// Endorse records the vote of a citizen, at most one per citizen and only
+// within the window of the proposal. It reports whether the vote counted. The
+// signature of the vote is not checked, see Merge.
+func (p *CategoryProposal) Endorse(e Endorsement) bool {
+	if e.CitizenID == "" || p.Endorsed(e.CitizenID) {
+		return false
+	}
+	at := e.EndorsedAt
+	if at.Before(p.ProposedAt) || at.Sub(p.ProposedAt) > ProposalWindow {
+		return false
+	}
+	// kept in time order, never sharing the backing array with copies of
+	// the proposal
+	i := sort.Search(len(p.Endorsements), func(i int) bool {
+		return p.Endorsements[i].EndorsedAt.After(at)
+	})
+	es := make([]Endorsement, 0, len(p.Endorsements)+1)
+	es = append(es, p.Endorsements[:i]...)
+	es = append(es, e)
+	p.Endorsements = append(es, p.Endorsements[i:]...)
+	return true
+}
+
+// Merge adds the endorsements of another copy of the proposal signed by the
+// keys of their citizens.
+func (p *CategoryProposal) Merge(o CategoryProposal, k *Keyring) {
+	for _, e := range o.Endorsements {
+		if e.Verify(p.ID, k) == nil {
+			p.Endorse(e)
+		}
+	}
+}
+
+// Verified returns a pending copy of a proposal read from a peer with only the
+// endorsements signed by the keys of their citizens, to be decided again.
+func (p CategoryProposal) Verified(k *Keyring) CategoryProposal {
+	v := p
+	v.Endorsements = nil
+	v.Status = ProposalPending
+	v.DecidedAt = time.Time{}
+	v.Merge(p, k)
+	return v
+}
+
+// Decide adopts the proposal once it reaches the quorum and expires it after
+// the window. Adoption is final, while an expired proposal can still be adopted
+// by endorsements of the window received late. It reports whether the status
+// changed.
+func (p *CategoryProposal) Decide(now time.Time) bool {
+	if p.Status == ProposalAdopted {
+		return false
+	}
+	prev := p.Status
+	switch {
+	case len(p.Endorsements) >= ProposalQuorum:
+		p.Status = ProposalAdopted
+		// adopted when the quorum was reached, the same on every peer
+		p.DecidedAt = p.Endorsements[ProposalQuorum-1].EndorsedAt
+	case now.Sub(p.ProposedAt) > ProposalWindow:
+		p.Status = ProposalExpired
+		p.DecidedAt = p.ProposedAt.Add(ProposalWindow)
+	default:
+		p.Status = ProposalPending
+	}
+	return p.Status != prev
+}
@@ -0,0 +1,121 @@
+package economy
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestProposalQuorum(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	k := NewKeyring()
+	cp := NewCategoryProposal("01HQ", Category{ID: "energy", Name: "Energy"}, "alice", at)
+
+	for i := 0; i < ProposalQuorum; i++ {
+		if cp.Decide(at) && cp.Status == ProposalAdopted {
+			t.Fatalf("adopted with %d endorsements", i)
+		}
+		e := NewEndorsement(newKey(t), cp.ID, at.Add(time.Duration(i)*time.Hour))
+		if err := e.Verify(cp.ID, k); err != nil {
+			t.Fatal(err)
+		}
+		if !cp.Endorse(e) || cp.Endorse(e) {
+			t.Fatalf("endorsement %d not counted exactly once", i)
+		}
+	}
+	if !cp.Decide(at) || cp.Status != ProposalAdopted {
+		t.Fatalf("got status %q, want adopted", cp.Status)
+	}
+	if want := at.Add(2 * time.Hour); !cp.DecidedAt.Equal(want) {
+		t.Fatalf("adopted at %v, want %v", cp.DecidedAt, want)
+	}
+
+	late := NewEndorsement(newKey(t), cp.ID, at.Add(ProposalWindow+time.Second))
+	if cp.Endorse(late) {
+		t.Fatal("endorsement after the window counted")
+	}
+}
+
+func TestForgedEndorsements(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	k := NewKeyring()
+	key := newKey(t)
+	stored := NewCategoryProposal("01HQ", Category{ID: "energy", Name: "Energy"}, "alice", at)
+	stored.Endorse(NewEndorsement(key, stored.ID, at))
+
+	// made up citizens, a vote signed for another proposal and a vote of a
+	// citizen signed with another key
+	stored.Endorse(Endorsement{CitizenID: "bob", EndorsedAt: at})
+	other := NewEndorsement(newKey(t), "01HR", at)
+	stored.Endorse(other)
+	stolen := NewEndorsement(key, stored.ID, at)
+	stolen.CitizenID = "carol"
+	stored.Endorse(stolen)
+	stored.Status = ProposalAdopted
+
+	for _, e := range stored.Endorsements[1:] {
+		if e.Verify(stored.ID, k) == nil {
+			t.Fatalf("forged endorsement of %s verified", e.CitizenID)
+		}
+	}
+
+	cp := stored.Verified(k)
+	if cp.Status != ProposalPending || len(cp.Endorsements) != 1 {
+		t.Fatalf("got %q with %d endorsements, want pending with 1", cp.Status, len(cp.Endorsements))
+	}
+	if cp.Decide(at) && cp.Status == ProposalAdopted {
+		t.Fatal("adopted with forged endorsements")
+	}
+}
+
+func TestRotatedEndorsement(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	old, key := newKey(t), newKey(t)
+	k := NewKeyring()
+	e := NewEndorsement(key, "01HQ", at)
+	e.CitizenID = Handle(old.Public().(ed25519.PublicKey))
+	e.Signature = ed25519.Sign(key, endorsementMessage("01HQ", e.CitizenID, at))
+	if e.Verify("01HQ", k) == nil {
+		t.Fatal("endorsement of another handle verified")
+	}
+	if err := k.Link(NewRotation(old, key, at)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Verify("01HQ", k); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignedProposal(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	k := NewKeyring()
+	key := newKey(t)
+	cp := NewCategoryProposal("01HQ", Category{ID: "energy", Name: "Energy"}, Handle(key.Public().(ed25519.PublicKey)), at)
+	if err := cp.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+
+	// endorsements and outcome are recorded by every peer after signing
+	for i := 0; i < ProposalQuorum; i++ {
+		cp.Endorse(NewEndorsement(newKey(t), cp.ID, at))
+	}
+	cp.Decide(at)
+	if err := cp.Verify(k); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := cp
+	changed.Category.Unit = "kWh"
+	if changed.Verify(k) == nil {
+		t.Fatal("changed proposal verified")
+	}
+}
@@ -0,0 +1,56 @@
+package economy
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// A category is short of supplies when the ratio of the demands of the last
+// ShortageWindow falls below ShortageThreshold.
+const (
+	ShortageWindow    = 10 * time.Minute
+	ShortageThreshold = 0.6
+)
+
+// Shortage is broadcast to every citizen when a category is short of supplies.
+type Shortage struct {
+	Category    string
+	Description string
+}
+
+// Ratio returns the supply/demand ratio of the requests between 0 and 1, the
+// average fulfilment of the demands. It is 1 without any demand.
+func Ratio(requests []Request) float64 {
+	if len(requests) == 0 {
+		return 1
+	}
+	var supplied float64
+	for _, r := range requests {
+		supplied += r.Fulfilment()
+	}
+	return supplied / float64(len(requests))
+}
+
+// Shortages returns the categories, in lower case and sorted, whose ratio over
+// the demands created in the ShortageWindow before now is below the
+// ShortageThreshold.
+func Shortages(requests []Request, now time.Time) []string {
+	recent := make(map[string][]Request)
+	for _, r := range requests {
+		if age := now.Sub(r.CreatedAt); age < 0 || age > ShortageWindow {
+			continue
+		}
+		cat := strings.ToLower(r.Category)
+		recent[cat] = append(recent[cat], r)
+	}
+
+	res := []string{}
+	for cat, rs := range recent {
+		if Ratio(rs) < ShortageThreshold {
+			res = append(res, cat)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
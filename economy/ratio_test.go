@@ -0,0 +1,62 @@
+package economy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []Request
+		want     float64
+	}{
+		{"no demand", nil, 1},
+		{"unsupplied", []Request{{Quantity: Quantity{Amount: 10}}}, 0},
+		{"fulfilled", []Request{{Quantity: Quantity{Amount: 10}, Fulfilled: true}}, 1},
+		{"partial", []Request{{Quantity: Quantity{Amount: 10}, Contributions: []Contribution{{Supplier: "bob", Amount: 4}}}}, 0.4},
+		{
+			"average",
+			[]Request{
+				{Quantity: Quantity{Amount: 10}, Fulfilled: true},
+				{Quantity: Quantity{Amount: 10}, Contributions: []Contribution{{Supplier: "bob", Amount: 5}}},
+				{Quantity: Quantity{Amount: 10}},
+			},
+			0.5,
+		},
+	}
+	for _, tt := range tests {
+		if got := Ratio(tt.requests); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShortages(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	demand := func(category string, age time.Duration, fulfilled bool) Request {
+		return Request{Category: category, Quantity: Quantity{Amount: 1}, CreatedAt: now.Add(-age), Fulfilled: fulfilled}
+	}
+	requests := []Request{
+		// water: 1 of 3 supplied
+		demand("water", time.Minute, true),
+		demand("Water", 2*time.Minute, false),
+		demand("water", 3*time.Minute, false),
+		// food: 2 of 3 supplied, above the threshold
+		demand("food", time.Minute, true),
+		demand("food", time.Minute, true),
+		demand("food", time.Minute, false),
+		// housing: unsupplied, but before the window and in the future
+		demand("housing", ShortageWindow+time.Second, false),
+		demand("housing", -time.Minute, false),
+		// energy: unsupplied at the edge of the window
+		demand("energy", ShortageWindow, false),
+	}
+	if got, want := Shortages(requests, now), []string{"energy", "water"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := Shortages(nil, now); len(got) != 0 {
+		t.Fatalf("got %v without demands", got)
+	}
+}
@@ -0,0 +1,107 @@
+package economy
+
+import "sort"
+
+// Ranking is the reputation of a citizen.
+type Ranking struct {
+	CitizenID       string
+	DemandRatio     float64 // number of personal demands compared to total
+	SupplyRatio     float64 // number of personal supplies compared to total
+	ReputationIndex float64 // SupplyRatio compared to DemandRatio
+}
+
+// Weigher returns the weight of a contribution in the rankings, 1 counts it
+// fully.
+type Weigher func(r Request, c Contribution) float64
+
+// Weights weigh what the rankings count. A nil function counts 1.
+type Weights struct {
+	Demand func(r Request) float64
+	Supply Weigher
+}
+
+func (w Weights) demand(r Request) float64 {
+	if w.Demand == nil {
+		return 1
+	}
+	return w.Demand(r)
+}
+
+func (w Weights) supply(r Request, c Contribution) float64 {
+	if w.Supply == nil {
+		return 1
+	}
+	return w.Supply(r, c)
+}
+
+// Discount returns the weights with every supply also weighted by d.
+func (w Weights) Discount(d Weigher) Weights {
+	supply := w.supply
+	w.Supply = func(r Request, c Contribution) float64 {
+		return supply(r, c) * d(r, c)
+	}
+	return w
+}
+
+// Rank returns the reputation of every citizen who demanded or supplied any of
+// the requests, best first.
+//
+// Supplies count by their share of the demand they contributed to. The
+// reputation index is the difference between the supply and the demand ratio,
+// weighted by the activity of the citizen relative to the number of requests.
+func Rank(requests []Request) []Ranking {
+	return RankWeighted(requests, Weights{})
+}
+
+// RankWeighted ranks like Rank with every demand and supply weighted by w. The
+// activity of a citizen is then relative to the weighted number of requests.
+func RankWeighted(requests []Request, w Weights) []Ranking {
+	citizens := []string{}
+	demands := make(map[string]float64)
+	supplies := make(map[string]float64)
+	var totalDemands, totalSupplies, weighted float64
+	seen := func(id string) {
+		if _, ok := demands[id]; !ok {
+			demands[id] = 0
+			citizens = append(citizens, id)
+		}
+	}
+
+	for _, r := range requests {
+		weight := w.demand(r)
+		weighted += weight
+		if r.CitizenID != "" {
+			seen(r.CitizenID)
+			demands[r.CitizenID] += weight
+			totalDemands += weight
+		}
+		for _, c := range r.Supplies() {
+			if c.Supplier == "" {
+				continue
+			}
+			seen(c.Supplier)
+			share := r.Share(c) * w.supply(r, c)
+			supplies[c.Supplier] += share
+			totalSupplies += share
+		}
+	}
+
+	ranks := make([]Ranking, 0, len(citizens))
+	for _, id := range citizens {
+		r := Ranking{CitizenID: id}
+		if totalDemands > 0 {
+			r.DemandRatio = demands[id] / totalDemands
+		}
+		if totalSupplies > 0 {
+			r.SupplyRatio = supplies[id] / totalSupplies
+		}
+		if weighted > 0 {
+			r.ReputationIndex = (r.SupplyRatio - r.DemandRatio) * ((demands[id] + supplies[id]) / weighted)
+		}
+		ranks = append(ranks, r)
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		return ranks[i].ReputationIndex > ranks[j].ReputationIndex
+	})
+	return ranks
+}
@@ -0,0 +1,68 @@
+package economy
+
+import (
+	"math"
+	"testing"
+)
+
+// rankingRequests are a demand of alice fully supplied by bob and a demand of
+// bob half supplied by alice.
+var rankingRequests = []Request{
+	{ID: "1", CitizenID: "alice", Quantity: Quantity{Amount: 10}, Contributions: []Contribution{{Supplier: "bob", Amount: 10}}, Fulfilled: true},
+	{ID: "2", CitizenID: "bob", Quantity: Quantity{Amount: 4}, Contributions: []Contribution{{Supplier: "alice", Amount: 2}}},
+}
+
+func checkRanks(t *testing.T, got []Ranking, want []Ranking) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d ranks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.CitizenID != w.CitizenID ||
+			math.Abs(g.DemandRatio-w.DemandRatio) > 1e-9 ||
+			math.Abs(g.SupplyRatio-w.SupplyRatio) > 1e-9 ||
+			math.Abs(g.ReputationIndex-w.ReputationIndex) > 1e-9 {
+			t.Errorf("rank %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestRankWeighted(t *testing.T) {
+	checkRanks(t, RankWeighted(rankingRequests, Weights{}), []Ranking{
+		{CitizenID: "bob", DemandRatio: 0.5, SupplyRatio: 2.0 / 3, ReputationIndex: 1.0 / 6},
+		{CitizenID: "alice", DemandRatio: 0.5, SupplyRatio: 1.0 / 3, ReputationIndex: -0.125},
+	})
+
+	// a demand weighing double
+	double := Weights{Demand: func(r Request) float64 {
+		if r.ID == "1" {
+			return 2
+		}
+		return 1
+	}}
+	checkRanks(t, RankWeighted(rankingRequests, double), []Ranking{
+		{CitizenID: "bob", DemandRatio: 1.0 / 3, SupplyRatio: 2.0 / 3, ReputationIndex: 2.0 / 9},
+		{CitizenID: "alice", DemandRatio: 2.0 / 3, SupplyRatio: 1.0 / 3, ReputationIndex: -5.0 / 18},
+	})
+
+	// the supplies of bob discounted away
+	discounted := Weights{}.Discount(func(r Request, c Contribution) float64 {
+		if c.Supplier == "bob" {
+			return 0
+		}
+		return 1
+	})
+	checkRanks(t, RankWeighted(rankingRequests, discounted), []Ranking{
+		{CitizenID: "alice", DemandRatio: 0.5, SupplyRatio: 1, ReputationIndex: 0.375},
+		{CitizenID: "bob", DemandRatio: 0.5, SupplyRatio: 0, ReputationIndex: -0.25},
+	})
+
+	if got := RankWeighted(nil, Weights{}); len(got) != 0 {
+		t.Fatalf("got %v without requests", got)
+	}
+}
+
+func TestRankIsClassic(t *testing.T) {
+	checkRanks(t, Rank(rankingRequests), RankWeighted(rankingRequests, Weights{}))
+}
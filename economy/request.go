@@ -0,0 +1,165 @@
+package economy
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quantity is an amount of a good or service, e.g. 100 litres.
+type Quantity struct {
+	Amount float64
+	Unit   string
+}
+
+// UnmarshalJSON also accepts the free text quantities of records created
+// before quantities had a unit, e.g. "5" or "5 litres", see ParseQuantity.
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	var legacy string
+	if err := json.Unmarshal(b, &legacy); err == nil {
+		*q = ParseQuantity(legacy)
+		return nil
+	}
+
+	type plain Quantity
+	return json.Unmarshal(b, (*plain)(q))
+}
+
+// ParseQuantity reads a free text quantity made of a number followed by a
+// unit, e.g. "5 L" is 5 litres. Registered units get their canonical symbol
+// and other text is kept as the unit. Text not starting with a number has an
+// unknown amount of 0.
+func ParseQuantity(s string) Quantity {
+	s = strings.TrimSpace(s)
+	end := 0
+	dot := false
+	for end < len(s) && ('0' <= s[end] && s[end] <= '9' || s[end] == '.' && !dot) {
+		dot = dot || s[end] == '.'
+		end++
+	}
+	amount, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return Quantity{}
+	}
+	return Quantity{Amount: amount, Unit: strings.TrimSpace(s[end:])}.Normalize()
+}
+
+func (q Quantity) String() string {
+	return strings.TrimSpace(strconv.FormatFloat(q.Amount, 'f', -1, 64) + " " + q.Unit)
+}
+
+// Request is a demand of a citizen.
+type Request struct {
+	ID        string
+	CitizenID string
+	Category  string
+	Quantity  Quantity
+	Details   string
+	CreatedAt time.Time
+	// Location is where the demand is to be supplied, if shared
+	Location *GeoPoint `json:",omitempty"`
+	// Contributions lists the partial supplies
+	Contributions []Contribution
+	Fulfilled     bool
+	FulfilledBy   string
+	FulfilledAt   time.Time
+	// Version is bumped by the requester on every update of the record
+	Version int
+	// Signer is the public key of the requester who signed the record.
+	Signer    []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+	// Legacy marks a record stored before records were signed. It is shown
+	// in the charts but never ranked, matched or supplied.
+	Legacy bool `json:"-"`
+}
+
+// Contribution is the part of a demand supplied by one citizen.
+type Contribution struct {
+	Supplier   string
+	Amount     float64
+	SuppliedAt time.Time
+	// OfferID is the Offer the contribution was taken from, if any.
+	OfferID string `json:",omitempty"`
+	// Claimed is the amount the supplier consented to, Amount is capped by
+	// the remaining quantity. Signer and Signature are the consent of the
+	// supplier.
+	Claimed   float64 `json:",omitempty"`
+	Signer    []byte  `json:",omitempty"`
+	Signature []byte  `json:",omitempty"`
+}
+
+// Supplies returns the contributions to the demand. Records fulfilled before
+// partial fulfilment existed count as a single contribution of the whole
+// quantity.
+func (r Request) Supplies() []Contribution {
+	if len(r.Contributions) == 0 && r.Fulfilled && r.FulfilledBy != "" {
+		return []Contribution{{
+			Supplier:   r.FulfilledBy,
+			Amount:     r.Quantity.Amount,
+			SuppliedAt: r.FulfilledAt,
+		}}
+	}
+	return r.Contributions
+}
+
+// Supplied returns the total amount supplied so far.
+func (r Request) Supplied() float64 {
+	var total float64
+	for _, c := range r.Supplies() {
+		total += c.Amount
+	}
+	return total
+}
+
+// Remaining returns the amount still to be supplied.
+func (r Request) Remaining() float64 {
+	if r.Fulfilled {
+		return 0
+	}
+	rem := r.Quantity.Amount - r.Supplied()
+	// tolerate the rounding of summed contributions
+	if rem < 1e-9 {
+		return 0
+	}
+	return rem
+}
+
+// Fulfilment returns the supplied share of the demand between 0 and 1.
+func (r Request) Fulfilment() float64 {
+	if r.Fulfilled {
+		return 1
+	}
+	if r.Quantity.Amount <= 0 {
+		return 0
+	}
+	return (r.Quantity.Amount - r.Remaining()) / r.Quantity.Amount
+}
+
+// Share returns the part of the demand covered by c between 0 and 1.
+func (r Request) Share(c Contribution) float64 {
+	if r.Quantity.Amount <= 0 {
+		// legacy records without a known amount were supplied whole
+		return 1
+	}
+	return c.Amount / r.Quantity.Amount
+}
+
+// Contribute adds c to the demand and returns the amount actually accepted,
+// which is capped by the remaining quantity.
+func (r *Request) Contribute(c Contribution) float64 {
+	if c.Amount > r.Remaining() {
+		c.Amount = r.Remaining()
+	}
+	if c.Amount <= 0 {
+		return 0
+	}
+	// never share the backing array with copies of the record
+	r.Contributions = append(r.Contributions[:len(r.Contributions):len(r.Contributions)], c)
+	if r.Remaining() == 0 {
+		r.Fulfilled = true
+		r.FulfilledBy = c.Supplier
+		r.FulfilledAt = c.SuppliedAt
+	}
+	return c.Amount
+}
@@ -0,0 +1,47 @@
+package economy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQuantityUnmarshalLegacy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Quantity
+	}{
+		{`{"Amount":5,"Unit":"kg"}`, Quantity{Amount: 5, Unit: "kg"}},
+		{`"5"`, Quantity{Amount: 5}},
+		{`" 2.5 "`, Quantity{Amount: 2.5}},
+		{`"5 litres"`, Quantity{Amount: 5, Unit: "litres"}},
+		{`"5L"`, Quantity{Amount: 5, Unit: "litres"}},
+		{`"10 Kilograms"`, Quantity{Amount: 10, Unit: "kg"}},
+		{`"3 bags of rice"`, Quantity{Amount: 3, Unit: "bags of rice"}},
+		{`"1.5.2 kg"`, Quantity{Amount: 1.5, Unit: ".2 kg"}},
+		{`"some water"`, Quantity{}},
+		{`""`, Quantity{}},
+	}
+	for _, tt := range tests {
+		var got Quantity
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLegacyDemandCanBeSupplied(t *testing.T) {
+	r := Request{}
+	if err := json.Unmarshal([]byte(`{"ID":"1","Quantity":"5 litres"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Quantity.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Remaining(); got != 5 {
+		t.Fatalf("remaining: got %v, want 5", got)
+	}
+}
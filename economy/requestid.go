@@ -0,0 +1,37 @@
+package economy
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// crockford is the base32 alphabet of request IDs. It keeps lexical order equal
+// to numeric order.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// RequestID returns a request ID in the ULID format: a 48 bit millisecond
+// timestamp followed by 80 bits of entropy, encoded in 26 characters. IDs sort
+// by creation time so they order the Ledger directly.
+func RequestID(t time.Time, entropy [10]byte) string {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	copy(b[6:], entropy[:])
+
+	// 128 bits are encoded as 26 characters of 5 bits, the first one
+	// carrying only 3 bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	id := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		id[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id)
+}
@@ -0,0 +1,273 @@
+package economy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The rankings are scored by a Strategy weighing what RankWeighted counts. The
+// classic strategy counts every demand and every whole supply as 1, the other
+// ones favour recent, scarce or large contributions.
+
+// Names of the scoring strategies.
+const (
+	ScoringClassic  = "classic"
+	ScoringDecayed  = "decayed"
+	ScoringScarcity = "scarcity"
+	ScoringQuantity = "quantity"
+)
+
+// ScoringHalfLife is the default age at which a demand or a supply counts half
+// in the decayed scoring.
+const ScoringHalfLife = 30 * 24 * time.Hour
+
+var ErrUnknownStrategy = errors.New("unknown scoring strategy")
+
+// Scoring is a scoring strategy with its settings.
+type Scoring struct {
+	Strategy string
+	// HalfLife is the age at which a demand or a supply counts half in the
+	// decayed scoring, ScoringHalfLife when 0.
+	HalfLife time.Duration
+}
+
+// Strategy is a way of scoring the citizens.
+type Strategy struct {
+	Name        string
+	Description string
+	// Weights returns the weights of the requests ranked at now.
+	Weights func(s Scoring, requests []Request, now time.Time) Weights
+}
+
+var strategies = []Strategy{
+	{
+		Name:        ScoringClassic,
+		Description: "Every demand and every supply counts the same",
+		Weights: func(Scoring, []Request, time.Time) Weights {
+			return Weights{}
+		},
+	},
+	{
+		Name:        ScoringDecayed,
+		Description: "Demands and supplies count half every half-life",
+		Weights:     decayedWeights,
+	},
+	{
+		Name:        ScoringScarcity,
+		Description: "Supplies count up to double the shorter of supplies their category was when demanded",
+		Weights:     scarcityWeights,
+	},
+	{
+		Name:        ScoringQuantity,
+		Description: "Demands and supplies count by their quantity relative to their category",
+		Weights:     quantityWeights,
+	},
+}
+
+// Strategies returns the scoring strategies, the classic one first.
+func Strategies() []Strategy {
+	return append([]Strategy(nil), strategies...)
+}
+
+// LookupStrategy returns the strategy of a name, the classic one for an empty
+// name.
+func LookupStrategy(name string) (Strategy, bool) {
+	if name == "" {
+		name = ScoringClassic
+	}
+	for _, s := range strategies {
+		if strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return Strategy{}, false
+}
+
+// Validate checks the strategy exists and the half-life is not negative.
+func (s Scoring) Validate() error {
+	if _, ok := LookupStrategy(s.Strategy); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownStrategy, s.Strategy)
+	}
+	if s.HalfLife < 0 {
+		return errors.New("negative scoring half-life")
+	}
+	return nil
+}
+
+// Weights returns the weights of the requests ranked at now. Unknown
+// strategies weigh like the classic one.
+func (s Scoring) Weights(requests []Request, now time.Time) Weights {
+	st, ok := LookupStrategy(s.Strategy)
+	if !ok {
+		return Weights{}
+	}
+	return st.Weights(s, requests, now)
+}
+
+// Rank ranks the citizens at now.
+func (s Scoring) Rank(requests []Request, now time.Time) []Ranking {
+	return RankWeighted(requests, s.Weights(requests, now))
+}
+
+// Decay returns the weight of something of an age, 1 when new and half every
+// halfLife. Future ages count 1.
+func Decay(age, halfLife time.Duration) float64 {
+	if age <= 0 || halfLife <= 0 {
+		return 1
+	}
+	return math.Exp2(-float64(age) / float64(halfLife))
+}
+
+// byCategory groups the requests by category in lower case.
+func byCategory(requests []Request) map[string][]Request {
+	res := make(map[string][]Request)
+	for _, r := range requests {
+		cat := strings.ToLower(r.Category)
+		res[cat] = append(res[cat], r)
+	}
+	return res
+}
+
+// decayedWeights weighs the demands by the time since they were created and
+// the supplies by the time since they were sent.
+func decayedWeights(s Scoring, _ []Request, now time.Time) Weights {
+	halfLife := s.HalfLife
+	if halfLife == 0 {
+		halfLife = ScoringHalfLife
+	}
+	return Weights{
+		Demand: func(r Request) float64 {
+			return Decay(now.Sub(r.CreatedAt), halfLife)
+		},
+		Supply: func(r Request, c Contribution) float64 {
+			return Decay(now.Sub(c.SuppliedAt), halfLife)
+		},
+	}
+}
+
+// scarcityWeights weighs the supplies by 2 minus the ratio of their category
+// when the demand was created: 1 when the category was fully
+// supplied and 2 when nobody supplied it.
+func scarcityWeights(_ Scoring, requests []Request, _ time.Time) Weights {
+	weights := make(map[string]float64, len(requests))
+	for _, rs := range byCategory(requests) {
+		sort.SliceStable(rs, func(i, j int) bool {
+			return rs[i].CreatedAt.Before(rs[j].CreatedAt)
+		})
+		for _, r := range rs {
+			weights[r.ID] = 2 - ratioAt(rs, r.ID, r.CreatedAt)
+		}
+	}
+	return Weights{Supply: func(r Request, c Contribution) float64 {
+		w, ok := weights[r.ID]
+		if !ok {
+			return 1
+		}
+		return w
+	}}
+}
+
+// ratioAt returns the supply/demand ratio at t of the requests of a category
+// sorted by creation time: the ratio of the demands created in the
+// ShortageWindow before t, but the one of ID skip, as they were supplied then.
+// The category was short of supplies at t when it is below the
+// ShortageThreshold.
+func ratioAt(sorted []Request, skip string, t time.Time) float64 {
+	from := sort.Search(len(sorted), func(i int) bool {
+		return !sorted[i].CreatedAt.Before(t.Add(-ShortageWindow))
+	})
+	to := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i].CreatedAt.After(t)
+	})
+	recent := make([]Request, 0, to-from)
+	for _, r := range sorted[from:to] {
+		if r.ID != skip {
+			recent = append(recent, r)
+		}
+	}
+	return Ratio(AsOf(recent, t))
+}
+
+// quantityWeights weighs the demands, and the supplies with them, by their
+// quantity relative to the mean quantity of their category. Quantities which
+// cannot be compared count 1.
+func quantityWeights(_ Scoring, requests []Request, _ time.Time) Weights {
+	totals := make(map[string]Total)
+	means := make(map[string]float64)
+	for cat, rs := range byCategory(requests) {
+		t := Aggregate(cat, "", rs)
+		if n := len(rs) - t.Skipped; n > 0 && t.Demanded > 0 {
+			totals[cat] = t
+			means[cat] = t.Demanded / float64(n)
+		}
+	}
+	weight := func(r Request) float64 {
+		cat := strings.ToLower(r.Category)
+		mean, ok := means[cat]
+		if !ok {
+			return 1
+		}
+		amount, err := ConvertAmount(r.Quantity.Amount, r.Quantity.Unit, totals[cat].Unit)
+		if err != nil || amount <= 0 {
+			return 1
+		}
+		return amount / mean
+	}
+	return Weights{
+		Demand: weight,
+		Supply: func(r Request, c Contribution) float64 {
+			return weight(r)
+		},
+	}
+}
+
+// AsOf returns the requests as they stood at t: the demands created by then
+// with the supplies sent by then.
+func AsOf(requests []Request, t time.Time) []Request {
+	res := make([]Request, 0, len(requests))
+	for _, r := range requests {
+		if r.CreatedAt.After(t) {
+			continue
+		}
+		if r.Fulfilled && r.FulfilledAt.After(t) {
+			r.Fulfilled = false
+		}
+		cs := make([]Contribution, 0, len(r.Contributions))
+		for _, c := range r.Contributions {
+			if !c.SuppliedAt.After(t) {
+				cs = append(cs, c)
+			}
+		}
+		r.Contributions = cs
+		res = append(res, r)
+	}
+	return res
+}
+
+// HistoryPoint is the reputation index of a citizen at a time.
+type HistoryPoint struct {
+	Time            time.Time
+	ReputationIndex float64
+}
+
+// History returns the reputation index of a citizen at every time, ranking
+// the requests as they stood then with rank. A citizen not ranked yet has an
+// index of 0.
+func History(requests []Request, citizenID string, times []time.Time, rank func(requests []Request, at time.Time) []Ranking) []HistoryPoint {
+	res := make([]HistoryPoint, 0, len(times))
+	for _, t := range times {
+		pt := HistoryPoint{Time: t}
+		for _, r := range rank(AsOf(requests, t), t) {
+			if r.CitizenID == citizenID {
+				pt.ReputationIndex = r.ReputationIndex
+				break
+			}
+		}
+		res = append(res, pt)
+	}
+	return res
+}
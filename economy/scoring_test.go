@@ -0,0 +1,78 @@
+package economy
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// scoringNow is when scoringRequests are ranked.
+var scoringNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// scoringRequests are demands of alice, bob, carol and dave over two months in
+// two categories, some supplied during a shortage of water.
+var scoringRequests = func() []Request {
+	at := func(d time.Duration) time.Time { return scoringNow.Add(-d) }
+	supply := func(supplier string, amount float64, d time.Duration) Contribution {
+		return Contribution{Supplier: supplier, Amount: amount, SuppliedAt: at(d)}
+	}
+	day := 24 * time.Hour
+	rs := []Request{
+		{ID: "01", CitizenID: "alice", Category: "water", Quantity: Quantity{Amount: 100, Unit: "litres"}, CreatedAt: at(60 * day),
+			Contributions: []Contribution{supply("bob", 100, 59*day)}},
+		{ID: "02", CitizenID: "bob", Category: "food", Quantity: Quantity{Amount: 5, Unit: "kg"}, CreatedAt: at(40 * day),
+			Contributions: []Contribution{supply("alice", 2, 39*day), supply("carol", 3, 38*day)}},
+		{ID: "03", CitizenID: "carol", Category: "water", Quantity: Quantity{Amount: 20, Unit: "litres"}, CreatedAt: at(10 * day),
+			Contributions: []Contribution{supply("dave", 20, 9*day)}},
+		// a shortage of water: three demands within minutes, one supplied
+		{ID: "04", CitizenID: "dave", Category: "water", Quantity: Quantity{Amount: 50, Unit: "litres"}, CreatedAt: at(2*day + 6*time.Minute)},
+		{ID: "05", CitizenID: "alice", Category: "water", Quantity: Quantity{Amount: 50, Unit: "litres"}, CreatedAt: at(2*day + 4*time.Minute)},
+		{ID: "06", CitizenID: "bob", Category: "water", Quantity: Quantity{Amount: 10, Unit: "litres"}, CreatedAt: at(2 * day),
+			Contributions: []Contribution{supply("carol", 10, day)}},
+		{ID: "07", CitizenID: "alice", Category: "food", Quantity: Quantity{Amount: 0.5, Unit: "t"}, CreatedAt: at(time.Hour),
+			Contributions: []Contribution{supply("dave", 0.25, 30*time.Minute)}},
+	}
+	for i := range rs {
+		if rs[i].Remaining() == 0 {
+			rs[i].Fulfilled = true
+			last := rs[i].Contributions[len(rs[i].Contributions)-1]
+			rs[i].FulfilledBy, rs[i].FulfilledAt = last.Supplier, last.SuppliedAt
+		}
+	}
+	return rs
+}()
+
+func formatRanks(ranks []Ranking) string {
+	var b strings.Builder
+	for _, r := range ranks {
+		fmt.Fprintf(&b, "%s %.6f %.6f %+.6f\n", r.CitizenID, r.DemandRatio, r.SupplyRatio, r.ReputationIndex)
+	}
+	return b.String()
+}
+
+func TestScoringGolden(t *testing.T) {
+	for _, st := range Strategies() {
+		t.Run(st.Name, func(t *testing.T) {
+			got := formatRanks(Scoring{Strategy: st.Name}.Rank(scoringRequests, scoringNow))
+			golden := filepath.Join("testdata", "scoring_"+st.Name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("got\n%swant\n%s", got, want)
+			}
+		})
+	}
+}
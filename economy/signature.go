@@ -0,0 +1,267 @@
+package economy
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Records are signed with the ed25519 key of their author. The requester
+// signs the whole demand record, suppliers sign their consent to supply it
+// when they claim it, and the consent is kept with the accepted contribution.
+// Offers are signed by their citizen and category proposals by their
+// proposer.
+
+var (
+	ErrUnsigned     = errors.New("unsigned record")
+	ErrBadSignature = errors.New("invalid signature")
+	ErrKeyMismatch  = errors.New("not signed with the key of the citizen")
+)
+
+// ConsentMessage is what a supplier signs when claiming an amount of a demand.
+func ConsentMessage(requestID, supplier string, amount float64, at time.Time, offerID string) []byte {
+	return []byte(requestID + "\n" + supplier + "\n" + strconv.FormatFloat(amount, 'g', -1, 64) + "\n" + at.UTC().Format(time.RFC3339Nano) + "\n" + offerID)
+}
+
+// Sign records the consent of the supplier to the contribution to demand
+// requestID. It must be called before the amount is capped by Contribute.
+func (c *Contribution) Sign(requestID string, key ed25519.PrivateKey) {
+	c.Claimed = c.Amount
+	c.Signer = key.Public().(ed25519.PublicKey)
+	c.Signature = ed25519.Sign(key, ConsentMessage(requestID, c.Supplier, c.Claimed, c.SuppliedAt, c.OfferID))
+}
+
+// Verify checks the consent of the supplier to the contribution.
+func (c Contribution) Verify(requestID string) error {
+	if len(c.Signer) != ed25519.PublicKeySize || len(c.Signature) == 0 {
+		return fmt.Errorf("%w: contribution of %s", ErrUnsigned, c.Supplier)
+	}
+	if !ed25519.Verify(c.Signer, ConsentMessage(requestID, c.Supplier, c.Claimed, c.SuppliedAt, c.OfferID), c.Signature) {
+		return fmt.Errorf("%w: contribution of %s", ErrBadSignature, c.Supplier)
+	}
+	if c.Amount > c.Claimed {
+		return fmt.Errorf("%w: contribution of %s exceeds its claim", ErrBadSignature, c.Supplier)
+	}
+	return nil
+}
+
+// signed returns the bytes the requester signs, the record without its
+// signature.
+func (r Request) signed() ([]byte, error) {
+	r.Signature = nil
+	return json.Marshal(r)
+}
+
+// Sign signs the record with the key of its requester. The contributions must
+// already hold the consent of their suppliers.
+func (r *Request) Sign(key ed25519.PrivateKey) error {
+	r.Signer = key.Public().(ed25519.PublicKey)
+	b, err := r.signed()
+	if err != nil {
+		return err
+	}
+	r.Signature = ed25519.Sign(key, b)
+	return nil
+}
+
+// Verify checks the signature of the record and the consent of every
+// supplier. It does not tell whether the keys belong to the citizens, see
+// Keyring.
+func (r Request) Verify() error {
+	if len(r.Signer) != ed25519.PublicKeySize || len(r.Signature) == 0 {
+		return fmt.Errorf("%w: demand %s", ErrUnsigned, r.ID)
+	}
+	b, err := r.signed()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(r.Signer, b, r.Signature) {
+		return fmt.Errorf("%w: demand %s", ErrBadSignature, r.ID)
+	}
+	if r.Fulfilled && len(r.Contributions) == 0 {
+		// supplied before contributions existed, the supplier never consented
+		return fmt.Errorf("%w: supply of demand %s", ErrUnsigned, r.ID)
+	}
+	for _, c := range r.Contributions {
+		if err := c.Verify(r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signed returns the bytes the citizen signs, the offer without its signature.
+func (o Offer) signed() ([]byte, error) {
+	o.Signature = nil
+	return json.Marshal(o)
+}
+
+// Sign signs the offer with the key of its citizen.
+func (o *Offer) Sign(key ed25519.PrivateKey) error {
+	o.Signer = key.Public().(ed25519.PublicKey)
+	b, err := o.signed()
+	if err != nil {
+		return err
+	}
+	o.Signature = ed25519.Sign(key, b)
+	return nil
+}
+
+// Verify checks that the citizen signed the offer with its own key.
+func (o Offer) Verify(k *Keyring) error {
+	if len(o.Signer) != ed25519.PublicKeySize || len(o.Signature) == 0 {
+		return fmt.Errorf("%w: offer %s", ErrUnsigned, o.ID)
+	}
+	b, err := o.signed()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(o.Signer, b, o.Signature) {
+		return fmt.Errorf("%w: offer %s", ErrBadSignature, o.ID)
+	}
+	return k.Check(o.CitizenID, o.Signer)
+}
+
+// signed returns the bytes the proposer signs, the proposal without its
+// endorsements, outcome and signature, which every peer records on its own.
+func (p CategoryProposal) signed() ([]byte, error) {
+	p.Endorsements = nil
+	p.Status = ""
+	p.DecidedAt = time.Time{}
+	p.Signature = nil
+	return json.Marshal(p)
+}
+
+// Sign signs the proposal with the key of its proposer.
+func (p *CategoryProposal) Sign(key ed25519.PrivateKey) error {
+	p.Signer = key.Public().(ed25519.PublicKey)
+	b, err := p.signed()
+	if err != nil {
+		return err
+	}
+	p.Signature = ed25519.Sign(key, b)
+	return nil
+}
+
+// Verify checks that the proposer signed the proposal with its own key. The
+// endorsements are verified on their own, see Endorsement.Verify.
+func (p CategoryProposal) Verify(k *Keyring) error {
+	if len(p.Signer) != ed25519.PublicKeySize || len(p.Signature) == 0 {
+		return fmt.Errorf("%w: proposal %s", ErrUnsigned, p.ID)
+	}
+	b, err := p.signed()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(p.Signer, b, p.Signature) {
+		return fmt.Errorf("%w: proposal %s", ErrBadSignature, p.ID)
+	}
+	return k.Check(p.ProposedBy, p.Signer)
+}
+
+// Keyring knows the key rotations of the citizens. A key signs for its own
+// handle and for every older handle of its citizen.
+type Keyring struct {
+	next map[string]Rotation
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{next: make(map[string]Rotation)}
+}
+
+// Link verifies and records a rotation. A handle rotates only once, when two
+// rotations of a handle are seen the earliest one wins on every peer.
+func (k *Keyring) Link(r Rotation) error {
+	if err := r.Verify(); err != nil {
+		return err
+	}
+	if cur, ok := k.next[r.From]; ok && !r.RotatedAt.Before(cur.RotatedAt) {
+		if cur.To == r.To {
+			return nil
+		}
+		return fmt.Errorf("%w: %s already rotated", ErrBadRotation, r.From)
+	}
+	if k.linked(r.To, r.From) {
+		return fmt.Errorf("%w: %s rotates back to itself", ErrBadRotation, r.From)
+	}
+	k.next[r.From] = r
+	return nil
+}
+
+// Latest returns the current handle of a citizen.
+func (k *Keyring) Latest(handle string) string {
+	// a chain is never longer than the number of rotations
+	for i := 0; i <= len(k.next); i++ {
+		r, ok := k.next[handle]
+		if !ok {
+			break
+		}
+		handle = r.To
+	}
+	return handle
+}
+
+// linked reports whether handle from rotated, directly or not, to handle to.
+func (k *Keyring) linked(from, to string) bool {
+	for i := 0; i <= len(k.next); i++ {
+		if from == to {
+			return true
+		}
+		r, ok := k.next[from]
+		if !ok {
+			return false
+		}
+		from = r.To
+	}
+	return false
+}
+
+// Check reports whether key signs for the citizen.
+func (k *Keyring) Check(citizenID string, key ed25519.PublicKey) error {
+	if !k.linked(citizenID, Handle(key)) {
+		return fmt.Errorf("%w: %s", ErrKeyMismatch, citizenID)
+	}
+	return nil
+}
+
+// Verify verifies the record and checks that the requester and suppliers
+// signed with their own keys.
+func (k *Keyring) Verify(r Request) error {
+	if err := r.Verify(); err != nil {
+		return err
+	}
+	if err := k.Check(r.CitizenID, r.Signer); err != nil {
+		return err
+	}
+	for _, c := range r.Contributions {
+		if err := k.Check(c.Supplier, c.Signer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Canonical returns copies of the records naming every citizen by its current
+// handle, so the history of a citizen follows it across rotations.
+func (k *Keyring) Canonical(requests []Request) []Request {
+	res := make([]Request, len(requests))
+	for i, r := range requests {
+		r.CitizenID = k.Latest(r.CitizenID)
+		if r.FulfilledBy != "" {
+			r.FulfilledBy = k.Latest(r.FulfilledBy)
+		}
+		cs := make([]Contribution, len(r.Contributions))
+		for j, c := range r.Contributions {
+			c.Supplier = k.Latest(c.Supplier)
+			cs[j] = c
+		}
+		if r.Contributions != nil {
+			r.Contributions = cs
+		}
+		res[i] = r
+	}
+	return res
+}
@@ -0,0 +1,225 @@
+package economy
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Running many identities costs nothing, so rankings can be inflated by
+// identities supplying each other. SybilPolicy detects the patterns of such
+// self-dealing and ranks citizens discounting the supplies of suspicious
+// identities, and of identities nobody trusted vouched for.
+
+// Reasons an identity is suspicious.
+const (
+	ReasonReciprocal   = "reciprocal supplies"
+	ReasonConcentrated = "supplies concentrated on one citizen"
+	ReasonRate         = "demand rate over the limit"
+)
+
+// SybilPolicy holds the thresholds of the sybil mitigation.
+type SybilPolicy struct {
+	// PairSupplies is the number of supplies from a citizen to another from
+	// which their relation is looked at.
+	PairSupplies int
+	// Concentration is the share of the supplies of a citizen going to a
+	// single requester from which the supplier is suspicious.
+	Concentration float64
+	// RateLimit is the number of demands an identity may create per
+	// RateWindow, the demands over the limit are not ranked.
+	RateLimit  int
+	RateWindow time.Duration
+	// SuspiciousWeight and UnvouchedWeight are the weights of the supplies of
+	// suspicious and unvouched identities in discounted rankings.
+	SuspiciousWeight float64
+	UnvouchedWeight  float64
+}
+
+// DefaultSybilPolicy returns the policy of the dashboard.
+func DefaultSybilPolicy() SybilPolicy {
+	return SybilPolicy{
+		PairSupplies:     3,
+		Concentration:    0.8,
+		RateLimit:        20,
+		RateWindow:       time.Hour,
+		SuspiciousWeight: 0,
+		UnvouchedWeight:  0.5,
+	}
+}
+
+// OverLimit returns the IDs of the demands created by their requester over the
+// rate limit.
+func (p SybilPolicy) OverLimit(requests []Request) map[string]bool {
+	byCitizen := make(map[string][]Request)
+	for _, r := range requests {
+		byCitizen[r.CitizenID] = append(byCitizen[r.CitizenID], r)
+	}
+	over := make(map[string]bool)
+	for _, rs := range byCitizen {
+		sort.Slice(rs, func(i, j int) bool {
+			return rs[i].CreatedAt.Before(rs[j].CreatedAt)
+		})
+		// counted demands in the window ending at the current one
+		window := []time.Time{}
+		for _, r := range rs {
+			for len(window) > 0 && !window[0].After(r.CreatedAt.Add(-p.RateWindow)) {
+				window = window[1:]
+			}
+			if len(window) >= p.RateLimit {
+				over[r.ID] = true
+				continue
+			}
+			window = append(window, r.CreatedAt)
+		}
+	}
+	return over
+}
+
+// Suspicious returns the reasons of every suspicious identity.
+func (p SybilPolicy) Suspicious(requests []Request) map[string][]string {
+	// supplies per supplier and requester
+	pairs := make(map[string]map[string]int)
+	totals := make(map[string]int)
+	for _, r := range requests {
+		for _, c := range r.Supplies() {
+			if c.Supplier == "" || c.Supplier == r.CitizenID {
+				continue
+			}
+			if pairs[c.Supplier] == nil {
+				pairs[c.Supplier] = make(map[string]int)
+			}
+			pairs[c.Supplier][r.CitizenID]++
+			totals[c.Supplier]++
+		}
+	}
+
+	reasons := make(map[string][]string)
+	flag := func(id, reason string) {
+		for _, r := range reasons[id] {
+			if r == reason {
+				return
+			}
+		}
+		reasons[id] = append(reasons[id], reason)
+	}
+	for supplier, requesters := range pairs {
+		for requester, n := range requesters {
+			if n < p.PairSupplies {
+				continue
+			}
+			if pairs[requester][supplier] >= p.PairSupplies {
+				flag(supplier, ReasonReciprocal)
+			}
+			if float64(n)/float64(totals[supplier]) >= p.Concentration {
+				flag(supplier, ReasonConcentrated)
+			}
+		}
+	}
+	over := p.OverLimit(requests)
+	for _, r := range requests {
+		if over[r.ID] {
+			flag(r.CitizenID, ReasonRate)
+		}
+	}
+	for _, rs := range reasons {
+		sort.Strings(rs)
+	}
+	return reasons
+}
+
+// Rank ranks the citizens with the weights w leaving out the demands over the
+// rate limit and discounting the supplies of suspicious identities and, when
+// trusted is not nil, of the identities it does not hold.
+func (p SybilPolicy) Rank(requests []Request, w Weights, trusted map[string]bool) []Ranking {
+	over := p.OverLimit(requests)
+	ranked := make([]Request, 0, len(requests))
+	for _, r := range requests {
+		if !over[r.ID] {
+			ranked = append(ranked, r)
+		}
+	}
+	suspicious := p.Suspicious(requests)
+	return RankWeighted(ranked, w.Discount(func(r Request, c Contribution) float64 {
+		if len(suspicious[c.Supplier]) > 0 {
+			return p.SuspiciousWeight
+		}
+		if trusted != nil && !trusted[c.Supplier] {
+			return p.UnvouchedWeight
+		}
+		return 1
+	}))
+}
+
+// Vouch is the statement of a citizen that another one is a distinct person.
+type Vouch struct {
+	Voucher   string
+	Citizen   string
+	VouchedAt time.Time
+	Signer    []byte
+	Signature []byte
+}
+
+func vouchMessage(voucher, citizen string, at time.Time) []byte {
+	return []byte("vouch\n" + voucher + "\n" + citizen + "\n" + at.UTC().Format(time.RFC3339Nano))
+}
+
+// NewVouch returns the vouch of the holder of key for a citizen.
+func NewVouch(key ed25519.PrivateKey, citizen string, at time.Time) Vouch {
+	v := Vouch{
+		Citizen:   citizen,
+		VouchedAt: at,
+		Signer:    key.Public().(ed25519.PublicKey),
+	}
+	v.Voucher = Handle(v.Signer)
+	v.Signature = ed25519.Sign(key, vouchMessage(v.Voucher, v.Citizen, v.VouchedAt))
+	return v
+}
+
+// Key returns the key of the vouch in a store, one vouch per pair.
+func (v Vouch) Key() string {
+	return v.Voucher + "/" + v.Citizen
+}
+
+// Verify checks the signature of the voucher.
+func (v Vouch) Verify(k *Keyring) error {
+	if v.Citizen == "" || strings.EqualFold(v.Voucher, v.Citizen) {
+		return fmt.Errorf("%w: vouch of %s", ErrBadSignature, v.Voucher)
+	}
+	if len(v.Signer) != ed25519.PublicKeySize || !ed25519.Verify(v.Signer, vouchMessage(v.Voucher, v.Citizen, v.VouchedAt), v.Signature) {
+		return fmt.Errorf("%w: vouch of %s", ErrBadSignature, v.Voucher)
+	}
+	return k.Check(v.Voucher, v.Signer)
+}
+
+// Trusted returns the citizens reachable from the roots through at most depth
+// vouches, the roots included. Handles are followed to their latest one.
+func Trusted(vouches []Vouch, k *Keyring, roots []string, depth int) map[string]bool {
+	edges := make(map[string][]string)
+	for _, v := range vouches {
+		from := k.Latest(v.Voucher)
+		edges[from] = append(edges[from], k.Latest(v.Citizen))
+	}
+	trusted := make(map[string]bool)
+	next := []string{}
+	for _, id := range roots {
+		id = k.Latest(id)
+		trusted[id] = true
+		next = append(next, id)
+	}
+	for d := 0; d < depth; d++ {
+		cur := next
+		next = nil
+		for _, id := range cur {
+			for _, to := range edges[id] {
+				if !trusted[to] {
+					trusted[to] = true
+					next = append(next, to)
+				}
+			}
+		}
+	}
+	return trusted
+}
@@ -0,0 +1,221 @@
+package economy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Dimension is what a unit measures. Only quantities of the same dimension
+// can be converted into each other.
+type Dimension string
+
+const (
+	DimensionVolume    Dimension = "volume"
+	DimensionMass      Dimension = "mass"
+	DimensionArea      Dimension = "area"
+	DimensionOccupancy Dimension = "occupancy"
+	DimensionService   Dimension = "service"
+	DimensionEnergy    Dimension = "energy"
+	DimensionCount     Dimension = "count"
+)
+
+// Unit is a registered unit of measure.
+type Unit struct {
+	// Symbol is the canonical name of the unit, e.g. "litres".
+	Symbol    string
+	Dimension Dimension
+	// Factor converts an amount of the unit into the base unit of its
+	// dimension, the base unit has a factor of 1.
+	Factor  float64
+	Aliases []string
+}
+
+var (
+	ErrUnknownUnit       = errors.New("unknown unit")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+	ErrInvalidAmount     = errors.New("invalid amount")
+)
+
+// units is the registry of the known units, base units first.
+var units = []Unit{
+	{Symbol: "litres", Dimension: DimensionVolume, Factor: 1, Aliases: []string{"l", "litre", "liter", "liters"}},
+	{Symbol: "ml", Dimension: DimensionVolume, Factor: 0.001, Aliases: []string{"millilitre", "millilitres", "milliliter", "milliliters"}},
+	{Symbol: "m³", Dimension: DimensionVolume, Factor: 1000, Aliases: []string{"m3", "cubic metre", "cubic metres", "cubic meter", "cubic meters"}},
+	{Symbol: "kg", Dimension: DimensionMass, Factor: 1, Aliases: []string{"kilo", "kilos", "kilogram", "kilograms"}},
+	{Symbol: "g", Dimension: DimensionMass, Factor: 0.001, Aliases: []string{"gram", "grams"}},
+	{Symbol: "t", Dimension: DimensionMass, Factor: 1000, Aliases: []string{"tonne", "tonnes", "ton", "tons"}},
+	{Symbol: "m²", Dimension: DimensionArea, Factor: 1, Aliases: []string{"m2", "sqm", "square metre", "square metres", "square meter", "square meters"}},
+	{Symbol: "ha", Dimension: DimensionArea, Factor: 10000, Aliases: []string{"hectare", "hectares"}},
+	{Symbol: "person-nights", Dimension: DimensionOccupancy, Factor: 1, Aliases: []string{"person-night", "person nights", "nights"}},
+	{Symbol: "hours of service", Dimension: DimensionService, Factor: 1, Aliases: []string{"h", "hour", "hours", "hour of service"}},
+	{Symbol: "days of service", Dimension: DimensionService, Factor: 8, Aliases: []string{"day of service", "days", "day"}},
+	{Symbol: "kWh", Dimension: DimensionEnergy, Factor: 1, Aliases: []string{"kilowatt-hour", "kilowatt-hours"}},
+	{Symbol: "MWh", Dimension: DimensionEnergy, Factor: 1000, Aliases: []string{"megawatt-hour", "megawatt-hours"}},
+	{Symbol: "pieces", Dimension: DimensionCount, Factor: 1, Aliases: []string{"piece", "pcs", "items", "item", "units", "unit"}},
+}
+
+// unitIndex maps the lowercase symbols and aliases to the registry.
+var unitIndex = func() map[string]Unit {
+	m := make(map[string]Unit)
+	for _, u := range units {
+		m[strings.ToLower(u.Symbol)] = u
+		for _, a := range u.Aliases {
+			m[strings.ToLower(a)] = u
+		}
+	}
+	return m
+}()
+
+// Units returns the registered units grouped by dimension.
+func Units() []Unit {
+	res := make([]Unit, len(units))
+	copy(res, units)
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Dimension < res[j].Dimension
+	})
+	return res
+}
+
+// LookupUnit returns the registered unit named s by its symbol or one of its
+// aliases, case-insensitively.
+func LookupUnit(s string) (Unit, bool) {
+	u, ok := unitIndex[strings.ToLower(strings.TrimSpace(s))]
+	return u, ok
+}
+
+// Compatible reports whether amounts in unit a can be converted into unit b.
+func Compatible(a, b string) bool {
+	ua, ok := LookupUnit(a)
+	if !ok {
+		return false
+	}
+	ub, ok := LookupUnit(b)
+	return ok && ua.Dimension == ub.Dimension
+}
+
+// Validate checks that the amount is a positive number and that the unit, if
+// any, is registered. Quantities without a unit are legacy records.
+func (q Quantity) Validate() error {
+	if math.IsNaN(q.Amount) || math.IsInf(q.Amount, 0) || q.Amount <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidAmount, q.Amount)
+	}
+	if q.Unit == "" {
+		return nil
+	}
+	if _, ok := LookupUnit(q.Unit); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownUnit, q.Unit)
+	}
+	return nil
+}
+
+// Normalize returns the quantity with the canonical symbol of its unit,
+// e.g. "5 L" becomes "5 litres". Unknown units are kept as they are.
+func (q Quantity) Normalize() Quantity {
+	if u, ok := LookupUnit(q.Unit); ok {
+		q.Unit = u.Symbol
+	}
+	return q
+}
+
+// Convert returns the quantity expressed in unit to.
+func (q Quantity) Convert(to string) (Quantity, error) {
+	from, ok := LookupUnit(q.Unit)
+	if !ok {
+		return Quantity{}, fmt.Errorf("%w: %q", ErrUnknownUnit, q.Unit)
+	}
+	u, ok := LookupUnit(to)
+	if !ok {
+		return Quantity{}, fmt.Errorf("%w: %q", ErrUnknownUnit, to)
+	}
+	if from.Dimension != u.Dimension {
+		return Quantity{}, fmt.Errorf("%w: %s and %s", ErrIncompatibleUnits, from.Symbol, u.Symbol)
+	}
+	return Quantity{Amount: q.Amount * from.Factor / u.Factor, Unit: u.Symbol}, nil
+}
+
+// ConvertAmount converts an amount from one unit into another. Equal units
+// never need a conversion and amounts of legacy quantities without a unit are
+// taken as they are.
+func ConvertAmount(amount float64, from, to string) (float64, error) {
+	if strings.EqualFold(from, to) || from == "" || to == "" {
+		return amount, nil
+	}
+	q, err := Quantity{Amount: amount, Unit: from}.Convert(to)
+	if err != nil {
+		return 0, err
+	}
+	return q.Amount, nil
+}
+
+// Total is the aggregated demand and supply of a category.
+type Total struct {
+	Category string
+	// Unit is the unit of the amounts, the unit of the category in the
+	// taxonomy when it is registered.
+	Unit     string
+	Demanded float64
+	Supplied float64
+	// Skipped counts the requests whose quantity could not be converted into
+	// the unit of the total.
+	Skipped int
+}
+
+// Remaining returns the amount demanded and not supplied yet.
+func (t Total) Remaining() float64 {
+	if t.Supplied >= t.Demanded {
+		return 0
+	}
+	return t.Demanded - t.Supplied
+}
+
+// Aggregate sums the quantities demanded and supplied by the requests of a
+// category, e.g. the requests of a ledger bucket, in unit. An empty unit is
+// the first unit seen.
+func Aggregate(category, unit string, requests []Request) Total {
+	tot := Total{Category: category, Unit: unit}
+	if u, ok := LookupUnit(unit); ok {
+		tot.Unit = u.Symbol
+	}
+	for _, r := range requests {
+		if tot.Unit == "" {
+			tot.Unit = r.Quantity.Normalize().Unit
+		}
+		demanded, err := ConvertAmount(r.Quantity.Amount, r.Quantity.Unit, tot.Unit)
+		if err != nil {
+			tot.Skipped++
+			continue
+		}
+		// contributions are in the unit of the demand
+		supplied, _ := ConvertAmount(r.Quantity.Amount-r.Remaining(), r.Quantity.Unit, tot.Unit)
+		tot.Demanded += demanded
+		tot.Supplied += supplied
+	}
+	return tot
+}
+
+// Totals aggregates the requests per category, sorted by category. Each total
+// is expressed in the unit of its category in the taxonomy, or in the first
+// unit seen for it. t may be nil.
+func Totals(requests []Request, t *Taxonomy) []Total {
+	byCategory := make(map[string][]Request)
+	for _, r := range requests {
+		cat := strings.ToLower(r.Category)
+		byCategory[cat] = append(byCategory[cat], r)
+	}
+
+	res := make([]Total, 0, len(byCategory))
+	for cat, rs := range byCategory {
+		unit := ""
+		if t != nil {
+			unit = t.Unit(cat)
+		}
+		res = append(res, Aggregate(cat, unit, rs))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Category < res[j].Category
+	})
+	return res
+}
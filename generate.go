@@ -0,0 +1,91 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/simulation"
+)
+
+func init() {
+	commands["generate"] = runGenerate
+}
+
+// runGenerate implements `cyber-stasis generate`, which writes the demand
+// records of a scenario to a storage backend.
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	path := fs.String("scenario", "", "JSON scenario file, the default scenario of the period when empty")
+	period := fs.String("period", Day, "period of the default scenario: hour, day, week, month or year")
+	seed := fs.Int64("seed", 0, "random seed, overrides the scenario")
+	backend := fs.String("ledger", LedgerBolt, "storage backend: bolt, orbit or json")
+	db := fs.String("db", "cyber-stasis.db", "bolt database file, or json output file, stdout when empty")
+	api := fs.String("api", "localhost:5001", "IPFS API address of the orbit ledger")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s := simulation.DefaultScenario(periodSpan(*period))
+	if *path != "" {
+		var err error
+		s, err = simulation.LoadScenario(*path)
+		if err != nil {
+			return err
+		}
+	}
+	if *seed != 0 {
+		s.Seed = *seed
+	}
+	requests, err := simulation.Generate(s, time.Now())
+	if err != nil {
+		return err
+	}
+
+	switch *backend {
+	case "json":
+		out := os.Stdout
+		if *db != "" {
+			out, err = os.Create(*db)
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(requests); err != nil {
+			return err
+		}
+	case LedgerBolt, LedgerOrbit:
+		var l Ledger
+		if *backend == LedgerBolt {
+			bl, err := newBoltLedger(*db)
+			if err != nil {
+				return err
+			}
+			defer bl.Close()
+			l = bl
+		} else {
+			l, err = openLedger(LedgerOrbit, *api, "")
+			if err != nil {
+				return err
+			}
+		}
+		for _, r := range requests {
+			if err := putRequest(l, r); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("unknown ledger " + *backend)
+	}
+
+	log.Printf("generated %d demands of %d citizens over %s", len(requests), s.Citizens, time.Duration(s.Span))
+	return nil
+}
@@ -6,9 +6,10 @@ toolchain go1.23.3
 
 require (
 	github.com/NYTimes/gziphandler v1.1.1
-	github.com/foolin/mixer v0.0.8
+	github.com/coder/websocket v1.8.12
 	github.com/maxence-charriere/go-app/v10 v10.0.8
 	github.com/stateless-minds/go-ipfs-api v0.7.5
+	go.etcd.io/bbolt v1.3.11
 )
 
 require (
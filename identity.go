@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// identityKey is where the identity of the citizen is kept in the browser
+// local storage.
+const identityKey = "identity"
+
+// signingKeyKey is where the dashboards kept the bare seed of the signing key
+// before identities could be rotated and exported.
+const signingKeyKey = "signingKey"
+
+// dbNameCitizenRotations holds the key rotations of the citizens under their
+// old handle.
+const dbNameCitizenRotations = "citizen_rotations"
+
+// messageRotation carries an economy.Rotation on the demand topic.
+const messageRotation = "rotation"
+
+// identity is the pseudonymous identity of the citizen. Its handle is derived
+// from the key, nothing ties it to the IPFS node or to a person.
+type identity struct {
+	// Key is the seed of the current signing key.
+	Key []byte
+	// Rotations link the previous handles of the citizen to the current one,
+	// oldest first.
+	Rotations []economy.Rotation `json:",omitempty"`
+}
+
+func (id identity) signingKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(id.Key)
+}
+
+func (id identity) handle() string {
+	return economy.Handle(id.signingKey().Public().(ed25519.PublicKey))
+}
+
+// validate checks the key and that the rotations lead to it.
+func (id identity) validate() error {
+	if len(id.Key) != ed25519.SeedSize {
+		return errors.New("invalid identity key")
+	}
+	for i, r := range id.Rotations {
+		if err := r.Verify(); err != nil {
+			return err
+		}
+		if i > 0 && id.Rotations[i-1].To != r.From {
+			return errors.New("identity rotations are not chained")
+		}
+	}
+	if n := len(id.Rotations); n > 0 && id.Rotations[n-1].To != id.handle() {
+		return errors.New("identity rotations do not lead to its key")
+	}
+	return nil
+}
+
+func generateKey() ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+// loadIdentity returns the identity of the citizen, generating it on the
+// first visit.
+func loadIdentity(ctx app.Context) identity {
+	id := identity{}
+	if err := ctx.LocalStorage().Get(identityKey, &id); err == nil && id.validate() == nil {
+		return id
+	}
+	var seed []byte
+	if err := ctx.LocalStorage().Get(signingKeyKey, &seed); err == nil && len(seed) == ed25519.SeedSize {
+		id = identity{Key: seed}
+	} else {
+		id = identity{Key: generateKey().Seed()}
+	}
+	saveIdentity(ctx, id)
+	return id
+}
+
+func saveIdentity(ctx app.Context, id identity) {
+	if err := ctx.LocalStorage().Set(identityKey, id); err != nil {
+		log.Println(err)
+	}
+}
+
+// setIdentity makes id the identity of the dashboard.
+func (p *pubsub) setIdentity(ctx app.Context, id identity) {
+	p.identity = id
+	p.key = id.signingKey()
+	p.citizenID = id.handle()
+	saveIdentity(ctx, id)
+	if p.market != nil {
+		p.matches = p.bestMatches()
+	}
+}
+
+// mine reports whether a handle is one of the handles of the citizen.
+func (p *pubsub) mine(citizenID string) bool {
+	return p.keys.Latest(citizenID) == p.citizenID
+}
+
+// rotateIdentity moves the citizen to a new key. The history of the old
+// handle follows the new one on every peer receiving the rotation.
+func (p *pubsub) rotateIdentity(ctx app.Context, e app.Event) {
+	key := generateKey()
+	r := economy.NewRotation(p.key, key, time.Now())
+	if err := p.keys.Link(r); err != nil {
+		p.createNotification(ctx, NotificationDanger, "Rotation failed!", err.Error())
+		return
+	}
+	id := p.identity
+	id.Key = key.Seed()
+	id.Rotations = append(id.Rotations[:len(id.Rotations):len(id.Rotations)], r)
+	p.setIdentity(ctx, id)
+	p.identityExport = ""
+	p.storeRotation(ctx, r)
+	p.ranks = p.rank()
+	p.createNotification(ctx, NotificationSuccess, "New key!", "You are now "+p.citizenID+". Export your identity again to keep the new key.")
+}
+
+// storeRotation persists and publishes a rotation.
+func (p *pubsub) storeRotation(ctx app.Context, r economy.Rotation) {
+	ctx.Async(func() {
+		rotation, err := json.Marshal(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// store in orbit-db first
+		err = p.ledger.Put(dbNameCitizenRotations, r.From, rotation)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = publishMessage(p.transport, p.topic, messageRotation, r)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+// fetchRotations lists the stored rotations, oldest first, and the malformed
+// records skipped. It runs in FetchAllRequests before the records are verified.
+func fetchRotations(l Ledger) ([]economy.Rotation, []error, error) {
+	rs, err := l.List(dbNameCitizenRotations)
+	if err != nil {
+		return nil, nil, err
+	}
+	rotations := make([]economy.Rotation, 0, len(rs))
+	malformed := []error{}
+	for key, v := range rs {
+		r := economy.Rotation{}
+		if err := json.Unmarshal(v, &r); err != nil {
+			malformed = append(malformed, malformedRecord(dbNameCitizenRotations, key, err))
+			continue
+		}
+		rotations = append(rotations, r)
+	}
+	sort.Slice(rotations, func(i, j int) bool {
+		return rotations[i].RotatedAt.Before(rotations[j].RotatedAt)
+	})
+	return rotations, malformed, nil
+}
+
+// exportIdentity shows the identity as text to copy to another device.
+func (p *pubsub) exportIdentity(ctx app.Context, e app.Event) {
+	b, err := json.Marshal(p.identity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.identityExport = base64.StdEncoding.EncodeToString(b)
+}
+
+func (p *pubsub) onIdentityInput(ctx app.Context, e app.Event) {
+	p.identityImport = strings.TrimSpace(ctx.JSSrc().Get("value").String())
+}
+
+// importIdentity replaces the identity of the dashboard with an exported one.
+func (p *pubsub) importIdentity(ctx app.Context, e app.Event) {
+	id := identity{}
+	b, err := base64.StdEncoding.DecodeString(p.identityImport)
+	if err == nil {
+		err = json.Unmarshal(b, &id)
+	}
+	if err == nil {
+		err = id.validate()
+	}
+	if err != nil {
+		p.createNotification(ctx, NotificationWarning, "Invalid identity!", "Paste the text exported from your other dashboard.")
+		return
+	}
+	for _, r := range id.Rotations {
+		if err := p.keys.Link(r); err != nil {
+			log.Println(err)
+		}
+	}
+	p.setIdentity(ctx, id)
+	p.identityImport = ""
+	p.identityExport = ""
+	p.ranks = p.rank()
+	p.createNotification(ctx, NotificationSuccess, "Welcome back!", "You are now playing as "+p.citizenID+".")
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+	shell "github.com/stateless-minds/go-ipfs-api"
+)
+
+const (
+	LedgerOrbit  = "orbit"
+	LedgerMemory = "memory"
+	LedgerBolt   = "bolt"
+)
+
+// ErrNotFound is returned by a Ledger when a key does not exist in a store.
+var ErrNotFound = errors.New("ledger: key not found")
+
+// Ledger is the storage backend behind the dashboard. Records are grouped in
+// named stores (dbNameSupplyDemand, dbNameCitizenReputation) and addressed by
+// string keys. Values are the JSON encoded records.
+type Ledger interface {
+	Put(store, key string, value []byte) error
+	Get(store, key string) ([]byte, error)
+	Delete(store, key string) error
+	// List returns every record of a store keyed by its key.
+	List(store string) (map[string][]byte, error)
+	// Clear removes every record of a store.
+	Clear(store string) error
+}
+
+// ledgerPath is where main serves its bolt ledger to the dashboards.
+const ledgerPath = "/ledger/"
+
+// serverHandlers are the handlers served by main next to the app, registered
+// by the files only built for the server.
+var serverHandlers = map[string]func() http.Handler{}
+
+// openLedger returns the ledger adapter of the given kind. The orbit adapter
+// talks to the IPFS API at api. The bolt adapter is file based: the server
+// tooling opens it with newBoltLedger and the dashboard reaches the one served
+// by main at ledgerURL.
+func openLedger(kind, api, ledgerURL string) (Ledger, error) {
+	switch kind {
+	case "", LedgerOrbit:
+		return &orbitLedger{sh: shell.NewShell(api)}, nil
+	case LedgerMemory:
+		return newMemoryLedger(), nil
+	case LedgerBolt:
+		return &httpLedger{url: ledgerURL}, nil
+	}
+	return nil, fmt.Errorf("ledger: unsupported backend %q", kind)
+}
+
+// putRequest stores a demand record under its ID.
+func putRequest(l Ledger, r economy.Request) error {
+	record, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return l.Put(dbNameSupplyDemand, r.ID, record)
+}
+
+// orbitLedger stores records in orbit-db through the IPFS HTTP API.
+// Reputation is kept in a document store, everything else in key-value stores.
+type orbitLedger struct {
+	sh *shell.Shell
+}
+
+func (l *orbitLedger) isDocs(store string) bool {
+	return store == dbNameCitizenReputation
+}
+
+func (l *orbitLedger) Put(store, key string, value []byte) error {
+	if l.isDocs(store) {
+		// documents carry their key in the _id field
+		return l.sh.OrbitDocsPut(store, value)
+	}
+	return l.sh.OrbitKVPut(store, key, value)
+}
+
+func (l *orbitLedger) Get(store, key string) ([]byte, error) {
+	var v []byte
+	var err error
+	if l.isDocs(store) {
+		v, err = l.sh.OrbitDocsGet(store, key)
+	} else {
+		v, err = l.sh.OrbitKVGet(store, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(v) == 0 {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (l *orbitLedger) Delete(store, key string) error {
+	if l.isDocs(store) {
+		return l.sh.OrbitDocsDelete(store, key)
+	}
+	return l.sh.OrbitKVDelete(store, key)
+}
+
+func (l *orbitLedger) List(store string) (map[string][]byte, error) {
+	records := make(map[string][]byte)
+	if l.isDocs(store) {
+		v, err := l.sh.OrbitDocsGet(store, "all")
+		if err != nil {
+			return nil, err
+		}
+		docs := []json.RawMessage{}
+		if err := json.Unmarshal(v, &docs); err != nil {
+			return nil, err
+		}
+		for _, d := range docs {
+			doc := struct {
+				ID string `json:"_id"`
+			}{}
+			if err := json.Unmarshal(d, &doc); err != nil {
+				return nil, err
+			}
+			records[doc.ID] = d
+		}
+		return records, nil
+	}
+
+	v, err := l.sh.OrbitKVGet(store, "all")
+	if err != nil {
+		return nil, err
+	}
+	// key-value stores return their values base64 encoded
+	ds := make(map[string]string)
+	if err := json.Unmarshal(v, &ds); err != nil {
+		return nil, err
+	}
+	for k, d := range ds {
+		dec, err := base64.URLEncoding.DecodeString(d)
+		if err != nil {
+			return nil, err
+		}
+		records[k] = dec
+	}
+	return records, nil
+}
+
+func (l *orbitLedger) Clear(store string) error {
+	if l.isDocs(store) {
+		return l.sh.OrbitDocsDelete(store, "all")
+	}
+	return l.sh.OrbitKVDelete(store, "all")
+}
+
+// memoryLedger keeps records in process memory. It is meant for local play
+// without an IPFS daemon and for tests; nothing survives a reload.
+type memoryLedger struct {
+	mu     sync.RWMutex
+	stores map[string]map[string][]byte
+}
+
+func newMemoryLedger() *memoryLedger {
+	return &memoryLedger{stores: make(map[string]map[string][]byte)}
+}
+
+func (l *memoryLedger) Put(store, key string, value []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.stores[store]
+	if !ok {
+		s = make(map[string][]byte)
+		l.stores[store] = s
+	}
+	s[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (l *memoryLedger) Get(store, key string) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	v, ok := l.stores[store][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (l *memoryLedger) Delete(store, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.stores[store], key)
+	return nil
+}
+
+func (l *memoryLedger) List(store string) (map[string][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	records := make(map[string][]byte, len(l.stores[store]))
+	for k, v := range l.stores[store] {
+		records[k] = append([]byte(nil), v...)
+	}
+	return records, nil
+}
+
+func (l *memoryLedger) Clear(store string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.stores, store)
+	return nil
+}
+
+// httpLedger is a ledger served by another process, see newLedgerHandler.
+type httpLedger struct {
+	url string
+}
+
+func (l *httpLedger) path(store string, key ...string) string {
+	p := l.url + url.PathEscape(store)
+	for _, k := range key {
+		p += "/" + url.PathEscape(k)
+	}
+	return p
+}
+
+func (l *httpLedger) do(method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	v, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case res.StatusCode >= 300:
+		return nil, fmt.Errorf("ledger: %s %s: %s", method, path, res.Status)
+	}
+	return v, nil
+}
+
+func (l *httpLedger) Put(store, key string, value []byte) error {
+	_, err := l.do(http.MethodPut, l.path(store, key), value)
+	return err
+}
+
+func (l *httpLedger) Get(store, key string) ([]byte, error) {
+	return l.do(http.MethodGet, l.path(store, key), nil)
+}
+
+func (l *httpLedger) Delete(store, key string) error {
+	_, err := l.do(http.MethodDelete, l.path(store, key), nil)
+	return err
+}
+
+func (l *httpLedger) List(store string) (map[string][]byte, error) {
+	v, err := l.do(http.MethodGet, l.path(store), nil)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string][]byte)
+	if err := json.Unmarshal(v, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (l *httpLedger) Clear(store string) error {
+	_, err := l.do(http.MethodDelete, l.path(store), nil)
+	return err
+}
+
+// newLedgerHandler serves a ledger at ledgerPath to httpLedger clients.
+func newLedgerHandler(l Ledger) http.Handler {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, v []byte, err error) {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.Write(v)
+		}
+	}
+	mux.HandleFunc("GET "+ledgerPath+"{store}", func(w http.ResponseWriter, r *http.Request) {
+		records, err := l.List(r.PathValue("store"))
+		if err != nil {
+			reply(w, nil, err)
+			return
+		}
+		v, err := json.Marshal(records)
+		reply(w, v, err)
+	})
+	mux.HandleFunc("DELETE "+ledgerPath+"{store}", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, nil, l.Clear(r.PathValue("store")))
+	})
+	mux.HandleFunc("GET "+ledgerPath+"{store}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		v, err := l.Get(r.PathValue("store"), r.PathValue("key"))
+		reply(w, v, err)
+	})
+	mux.HandleFunc("PUT "+ledgerPath+"{store}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		v, err := io.ReadAll(r.Body)
+		if err != nil {
+			reply(w, nil, err)
+			return
+		}
+		reply(w, nil, l.Put(r.PathValue("store"), r.PathValue("key"), v))
+	})
+	mux.HandleFunc("DELETE "+ledgerPath+"{store}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, nil, l.Delete(r.PathValue("store"), r.PathValue("key")))
+	})
+	return mux
+}
@@ -0,0 +1,106 @@
+//go:build !js
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltPath is the database served by main to the dashboards opened with
+// ?ledger=bolt, the default one of `cyber-stasis generate`.
+const boltPath = "cyber-stasis.db"
+
+func init() {
+	serverHandlers[ledgerPath] = func() http.Handler {
+		l, err := newBoltLedger(boltPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return newLedgerHandler(l)
+	}
+}
+
+// boltLedger stores records in a local BoltDB file, one bucket per store. It
+// lets the server and its tooling run against a ledger without an IPFS daemon.
+type boltLedger struct {
+	db *bolt.DB
+}
+
+func newBoltLedger(path string) (*boltLedger, error) {
+	// fail instead of waiting for the file lock of a running server
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &boltLedger{db: db}, nil
+}
+
+func (l *boltLedger) Close() error {
+	return l.db.Close()
+}
+
+func (l *boltLedger) Put(store, key string, value []byte) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(store))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+func (l *boltLedger) Get(store, key string) ([]byte, error) {
+	var v []byte
+	err := l.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(store))
+		if b == nil {
+			return ErrNotFound
+		}
+		val := b.Get([]byte(key))
+		if val == nil {
+			return ErrNotFound
+		}
+		// values are only valid for the life of the transaction
+		v = append([]byte(nil), val...)
+		return nil
+	})
+	return v, err
+}
+
+func (l *boltLedger) Delete(store, key string) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(store))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+func (l *boltLedger) List(store string) (map[string][]byte, error) {
+	records := make(map[string][]byte)
+	err := l.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(store))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			records[string(k)] = append([]byte(nil), v...)
+			return nil
+		})
+	})
+	return records, err
+}
+
+func (l *boltLedger) Clear(store string) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(store)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket([]byte(store))
+	})
+}
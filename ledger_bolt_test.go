@@ -0,0 +1,33 @@
+//go:build !js
+
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltLedger(t *testing.T) {
+	l, err := newBoltLedger(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	testLedger(t, l)
+}
+
+func TestServedBoltLedger(t *testing.T) {
+	bl, err := newBoltLedger(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+	srv := httptest.NewServer(newLedgerHandler(bl))
+	defer srv.Close()
+	l, err := openLedger(LedgerBolt, "", srv.URL+ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLedger(t, l)
+}
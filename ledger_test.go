@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+// testLedger checks the contract every Ledger adapter must honour.
+func testLedger(t *testing.T, l Ledger) {
+	t.Helper()
+
+	if _, err := l.Get(dbNameSupplyDemand, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get of a missing key: got %v, want ErrNotFound", err)
+	}
+	records, err := l.List("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("list of an empty store: got %d records", len(records))
+	}
+
+	value := []byte(`{"ID":"01HQ"}`)
+	if err := l.Put(dbNameSupplyDemand, "01HQ", value); err != nil {
+		t.Fatal(err)
+	}
+	// the adapter keeps its own copy
+	value[2] = 'X'
+	v, err := l.Get(dbNameSupplyDemand, "01HQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte(`{"ID":"01HQ"}`); !bytes.Equal(v, want) {
+		t.Fatalf("get: got %s, want %s", v, want)
+	}
+
+	if err := l.Put(dbNameSupplyDemand, "01HQ", []byte(`{"ID":"01HQ","Version":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put(dbNameSupplyDemand, "key with/slash", []byte(`2`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put(dbNameCitizenReputation, "citizen", []byte(`3`)); err != nil {
+		t.Fatal(err)
+	}
+	records, err = l.List(dbNameSupplyDemand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || string(records["01HQ"]) != `{"ID":"01HQ","Version":1}` || string(records["key with/slash"]) != `2` {
+		t.Fatalf("list: got %q", records)
+	}
+
+	if err := l.Delete(dbNameSupplyDemand, "01HQ"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(dbNameSupplyDemand, "01HQ"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get of a deleted key: got %v, want ErrNotFound", err)
+	}
+	if err := l.Delete(dbNameSupplyDemand, "01HQ"); err != nil {
+		t.Fatalf("delete of a missing key: %v", err)
+	}
+
+	if err := l.Clear(dbNameSupplyDemand); err != nil {
+		t.Fatal(err)
+	}
+	records, err = l.List(dbNameSupplyDemand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("list of a cleared store: got %d records", len(records))
+	}
+	// other stores are left alone
+	if v, err := l.Get(dbNameCitizenReputation, "citizen"); err != nil || string(v) != `3` {
+		t.Fatalf("get from another store after clear: got %s, %v", v, err)
+	}
+}
+
+func TestMemoryLedger(t *testing.T) {
+	testLedger(t, newMemoryLedger())
+}
+
+func TestHTTPLedger(t *testing.T) {
+	srv := httptest.NewServer(newLedgerHandler(newMemoryLedger()))
+	defer srv.Close()
+	l, err := openLedger(LedgerBolt, "", srv.URL+ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLedger(t, l)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// capabilitiesKey is where the capabilities of the citizen are kept in the
+// browser local storage.
+const capabilitiesKey = "capabilities"
+
+// bestMatchesLimit is the number of suggestions shown in the dashboard.
+const bestMatchesLimit = 5
+
+// bestMatches returns the top suggestions for the pending demands. The
+// handlers changing the market, the capabilities, the categories or the
+// citizen keep them in p.matches.
+func (p *pubsub) bestMatches() []economy.Suggestion {
+	if !p.hasCapabilities {
+		return nil
+	}
+	// providing a category provides its subcategories
+	c := p.capabilities
+	if len(c.Categories) > 0 {
+		c.Categories = p.taxonomy.Expand(c.Categories)
+	}
+	res := economy.RankMatches(c, p.market.Signed(), p.citizenID, time.Now())
+	if len(res) > bestMatchesLimit {
+		res = res[:bestMatchesLimit]
+	}
+	return res
+}
+
+// suggestion returns the best match suggestion shown for a demand, if any.
+func (p *pubsub) suggestion(requestID string) (economy.Suggestion, bool) {
+	for _, s := range p.matches {
+		if s.RequestID == requestID {
+			return s, true
+		}
+	}
+	return economy.Suggestion{}, false
+}
+
+func (p *pubsub) loadCapabilities(ctx app.Context) {
+	c := economy.Capabilities{}
+	if err := ctx.LocalStorage().Get(capabilitiesKey, &c); err == nil && (len(c.Categories) > 0 || c.Location != nil) {
+		p.capabilities = c
+		p.hasCapabilities = true
+	}
+	p.matches = p.bestMatches()
+}
+
+func (p *pubsub) onCapabilitiesInput(ctx app.Context, e app.Event) {
+	v := strings.TrimSpace(ctx.JSSrc().Get("value").String())
+	switch ctx.JSSrc().Get("name").String() {
+	case "capability-category":
+		cats := []string{}
+		for _, cat := range p.capabilities.Categories {
+			if !strings.EqualFold(cat, v) {
+				cats = append(cats, cat)
+			}
+		}
+		if ctx.JSSrc().Get("checked").Bool() {
+			cats = append(cats, v)
+		}
+		p.capabilities.Categories = cats
+	case "capability-min":
+		p.capabilities.MinQuantity, _ = strconv.ParseFloat(v, 64)
+	case "capability-max":
+		p.capabilities.MaxQuantity, _ = strconv.ParseFloat(v, 64)
+	case "capability-radius":
+		p.capabilities.RadiusKm, _ = strconv.ParseFloat(v, 64)
+	case "capability-from":
+		p.capabilities.AvailableFrom, _ = time.ParseInLocation("2006-01-02", v, time.Local)
+	case "capability-until":
+		until, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			p.capabilities.AvailableUntil = time.Time{}
+			break
+		}
+		// available until the end of the day
+		p.capabilities.AvailableUntil = until.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	p.saveCapabilities(ctx)
+}
+
+func (p *pubsub) onCapabilitiesLocation(ctx app.Context, e app.Event) {
+	locate(ctx, func(ctx app.Context, g economy.GeoPoint) {
+		p.capabilities.Location = &g
+		p.saveCapabilities(ctx)
+	})
+}
+
+func (p *pubsub) saveCapabilities(ctx app.Context) {
+	p.hasCapabilities = true
+	p.matches = p.bestMatches()
+	if err := ctx.LocalStorage().Set(capabilitiesKey, p.capabilities); err != nil {
+		log.Println(err)
+	}
+}
+
+// locate asks the browser for the current position of the citizen.
+func locate(ctx app.Context, fn func(ctx app.Context, g economy.GeoPoint)) {
+	geo := app.Window().Get("navigator").Get("geolocation")
+	if !geo.Truthy() {
+		return
+	}
+	var success app.Func
+	success = app.FuncOf(func(this app.Value, args []app.Value) any {
+		coords := args[0].Get("coords")
+		g := economy.GeoPoint{Lat: coords.Get("latitude").Float(), Lon: coords.Get("longitude").Float()}
+		ctx.Dispatch(func(ctx app.Context) {
+			fn(ctx, g)
+		})
+		success.Release()
+		return nil
+	})
+	geo.Call("getCurrentPosition", success)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// Message types published on the demand topic.
+const (
+	// messageDemand carries a new demandRequest record.
+	messageDemand = "demand"
+	// messageClaim carries a supplyClaim of a supplier on a pending demand.
+	messageClaim = "claim"
+	// messageConfirm carries the demandRequest record updated by the
+	// requester after accepting a claim.
+	messageConfirm = "confirm"
+	// messageReject carries a claimRejection, a supplyClaim the requester
+	// has turned down.
+	messageReject = "reject"
+)
+
+// messageShortage carries an economy.Shortage alert published on
+// topicCritical.
+const messageShortage = "shortage"
+
+// schemaVersion is the version of the envelope and payloads published by this
+// peer. Version 2 signs every record and adds the key rotations and vouches.
+// The unsigned payloads of version 1, and of the envelopes without a version
+// that predate it, are rejected.
+const schemaVersion = 2
+
+// maxClockSkew is how far in the future the timestamps of a peer may be.
+const maxClockSkew = 5 * time.Minute
+
+// envelope wraps every message published on the topics so peers can tell
+// records and protocol messages apart.
+type envelope struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func newEnvelope(typ string, v any) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{Type: typ, Version: schemaVersion, Payload: payload})
+}
+
+// publishMessage wraps v in an envelope of the given type and publishes it.
+func publishMessage(t Transport, topic, typ string, v any) error {
+	msg, err := newEnvelope(typ, v)
+	if err != nil {
+		return err
+	}
+	return t.Publish(topic, msg)
+}
+
+// Reasons a message of a peer is rejected.
+var (
+	errMalformed          = errors.New("malformed message")
+	errUnsupportedVersion = errors.New("unsupported schema version")
+	errUnknownType        = errors.New("unknown message type")
+	errInvalid            = errors.New("invalid message")
+	errUnverified         = errors.New("unsigned or forged record")
+	errRateLimited        = errors.New("over the rate limit")
+)
+
+// message is a decoded and validated message of a peer. Payload holds an
+// economy.Request, supplyClaim, claimRejection, economy.Rotation, economy.Vouch, economy.Offer,
+// economy.CategoryProposal, categoryEndorsement or economy.Shortage depending
+// on Type.
+type message struct {
+	Type    string
+	Payload any
+}
+
+// decodeMessage decodes a message received on any topic, validates its
+// payload against the taxonomy and verifies the signatures of its records
+// against the keyring. It never panics on hostile input, every failure
+// is an error wrapping one of the rejection reasons.
+func decodeMessage(data []byte, t *economy.Taxonomy, k *economy.Keyring, now time.Time) (message, error) {
+	env := envelope{}
+	if err := decodeStrict(data, &env); err != nil {
+		return message{}, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	if env.Version != schemaVersion {
+		return message{}, fmt.Errorf("%w: %d", errUnsupportedVersion, env.Version)
+	}
+
+	m := message{Type: env.Type}
+	var err error
+	switch env.Type {
+	case messageDemand, messageConfirm:
+		d := economy.Request{}
+		if err = decodeStrict(env.Payload, &d); err == nil {
+			m.Payload = d
+			if err := validateDemand(d, t, now); err != nil {
+				return m, err
+			}
+			return m, verified(k.Verify(d))
+		}
+	case messageClaim:
+		c := supplyClaim{}
+		if err = decodeStrict(env.Payload, &c); err == nil {
+			m.Payload = c
+			return m, verifyClaim(c, k, now)
+		}
+	case messageReject:
+		r := claimRejection{}
+		if err = decodeStrict(env.Payload, &r); err == nil {
+			m.Payload = r
+			if err := verifyClaim(r.Claim, k, now); err != nil {
+				return m, err
+			}
+			if r.Requester == "" {
+				return m, invalid("rejection of a claim on %s without requester", r.Claim.RequestID)
+			}
+			if err := validateTime("rejection time", r.RejectedAt, now); err != nil {
+				return m, err
+			}
+			// the requester of the demand is checked against the market
+			if err := r.verify(); err != nil {
+				return m, verified(err)
+			}
+			return m, verified(k.Check(r.Requester, r.Signer))
+		}
+	case messageOffer:
+		o := economy.Offer{}
+		if err = decodeStrict(env.Payload, &o); err == nil {
+			m.Payload = o
+			if err := validateOffer(o, t, now); err != nil {
+				return m, err
+			}
+			return m, verified(o.Verify(k))
+		}
+	case messageProposal:
+		cp := economy.CategoryProposal{}
+		if err = decodeStrict(env.Payload, &cp); err == nil {
+			m.Payload = cp
+			if err := validateProposal(cp, t, now); err != nil {
+				return m, err
+			}
+			return m, verified(cp.Verify(k))
+		}
+	case messageRotation:
+		r := economy.Rotation{}
+		if err = decodeStrict(env.Payload, &r); err == nil {
+			m.Payload = r
+			if err := validateTime("rotation time", r.RotatedAt, now); err != nil {
+				return m, err
+			}
+			return m, verified(r.Verify())
+		}
+	case messageVouch:
+		v := economy.Vouch{}
+		if err = decodeStrict(env.Payload, &v); err == nil {
+			m.Payload = v
+			if err := validateTime("vouch time", v.VouchedAt, now); err != nil {
+				return m, err
+			}
+			return m, verified(v.Verify(k))
+		}
+	case messageEndorse:
+		v := categoryEndorsement{}
+		if err = decodeStrict(env.Payload, &v); err == nil {
+			m.Payload = v
+			if err := validateEndorsement(v, now); err != nil {
+				return m, err
+			}
+			return m, verified(v.Verify(v.ProposalID, k))
+		}
+	case messageShortage:
+		s := economy.Shortage{}
+		if err = decodeStrict(env.Payload, &s); err == nil {
+			m.Payload = s
+			return m, validateCategory(s.Category, t)
+		}
+	default:
+		return m, fmt.Errorf("%w: %q", errUnknownType, env.Type)
+	}
+	return m, fmt.Errorf("%w: %s payload: %v", errMalformed, env.Type, err)
+}
+
+// malformedRecord is the error counted for a record of a shared store that
+// does not decode. Stores are replicated from every peer, so such records are
+// skipped instead of stopping the dashboard.
+func malformedRecord(store, key string, err error) error {
+	return fmt.Errorf("%w: %s record %s: %v", errMalformed, store, key, err)
+}
+
+// verified wraps the error of a verification into errUnverified.
+func verified(err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnverified, err)
+	}
+	return nil
+}
+
+// decodeStrict decodes a single JSON value with no unknown fields.
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+func invalid(format string, v ...any) error {
+	return fmt.Errorf("%w: %s", errInvalid, fmt.Sprintf(format, v...))
+}
+
+func validateTime(name string, at, now time.Time) error {
+	if at.IsZero() {
+		return invalid("missing %s", name)
+	}
+	if at.After(now.Add(maxClockSkew)) {
+		return invalid("%s %s is in the future", name, at.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func validateCategory(category string, t *economy.Taxonomy) error {
+	if _, ok := t.Get(category); !ok {
+		return invalid("unknown category %q", category)
+	}
+	return nil
+}
+
+func validateDemand(d economy.Request, t *economy.Taxonomy, now time.Time) error {
+	if d.ID == "" || d.CitizenID == "" {
+		return invalid("demand without ID or citizen")
+	}
+	if d.Version < 0 {
+		return invalid("demand %s has a negative version", d.ID)
+	}
+	if err := validateCategory(d.Category, t); err != nil {
+		return err
+	}
+	if err := d.Quantity.Validate(); err != nil {
+		return invalid("demand %s: %v", d.ID, err)
+	}
+	if err := validateTime("creation time", d.CreatedAt, now); err != nil {
+		return err
+	}
+	var supplied float64
+	for _, c := range d.Contributions {
+		if c.Supplier == "" {
+			return invalid("demand %s has a contribution without supplier", d.ID)
+		}
+		if err := (economy.Quantity{Amount: c.Amount, Unit: d.Quantity.Unit}).Validate(); err != nil {
+			return invalid("demand %s contribution: %v", d.ID, err)
+		}
+		if err := validateTime("supply time", c.SuppliedAt, now); err != nil {
+			return err
+		}
+		if c.SuppliedAt.Before(d.CreatedAt.Add(-maxClockSkew)) {
+			return invalid("demand %s was supplied before its creation", d.ID)
+		}
+		supplied += c.Amount
+	}
+	// tolerate the rounding of summed contributions
+	if supplied > d.Quantity.Amount*(1+1e-9) {
+		return invalid("demand %s is oversupplied", d.ID)
+	}
+	if d.Fulfilled {
+		return validateTime("fulfilment time", d.FulfilledAt, now)
+	}
+	return nil
+}
+
+func validateClaim(c supplyClaim, now time.Time) error {
+	if c.RequestID == "" || c.Supplier == "" {
+		return invalid("claim without demand or supplier")
+	}
+	if c.Version < 0 {
+		return invalid("claim on %s has a negative version", c.RequestID)
+	}
+	if err := (economy.Quantity{Amount: c.Amount}).Validate(); err != nil {
+		return invalid("claim on %s: %v", c.RequestID, err)
+	}
+	return validateTime("claim time", c.ClaimedAt, now)
+}
+
+// verifyClaim validates a claim and checks the consent of its supplier.
+func verifyClaim(c supplyClaim, k *economy.Keyring, now time.Time) error {
+	if err := validateClaim(c, now); err != nil {
+		return err
+	}
+	if err := c.verify(); err != nil {
+		return verified(err)
+	}
+	return verified(k.Check(c.Supplier, c.Signer))
+}
+
+func validateOffer(o economy.Offer, t *economy.Taxonomy, now time.Time) error {
+	if o.ID == "" || o.CitizenID == "" {
+		return invalid("offer without ID or citizen")
+	}
+	if err := validateCategory(o.Category, t); err != nil {
+		return err
+	}
+	if err := o.Quantity.Validate(); err != nil {
+		return invalid("offer %s: %v", o.ID, err)
+	}
+	if err := validateTime("creation time", o.CreatedAt, now); err != nil {
+		return err
+	}
+	for _, m := range o.Matches {
+		if m.RequestID == "" || m.Amount <= 0 {
+			return invalid("offer %s has an invalid match", o.ID)
+		}
+		if err := validateTime("match time", m.MatchedAt, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateProposal(cp economy.CategoryProposal, t *economy.Taxonomy, now time.Time) error {
+	if cp.ID == "" || cp.ProposedBy == "" || cp.Category.ID == "" {
+		return invalid("proposal without ID, proposer or category")
+	}
+	if strings.EqualFold(cp.Category.ID, economy.CategoryAll) {
+		return invalid("proposal %s of the reserved category %q", cp.ID, cp.Category.ID)
+	}
+	if cp.Category.Parent != "" {
+		if err := validateCategory(cp.Category.Parent, t); err != nil {
+			return err
+		}
+	}
+	if u := cp.Category.Unit; u != "" {
+		if _, ok := economy.LookupUnit(u); !ok {
+			return invalid("proposal %s has the unknown unit %q", cp.ID, u)
+		}
+	}
+	if err := validateTime("proposal time", cp.ProposedAt, now); err != nil {
+		return err
+	}
+	// votes only count when received on their own, see categoryEndorsement
+	if len(cp.Endorsements) > 0 {
+		return invalid("proposal %s arrives with endorsements", cp.ID)
+	}
+	if cp.Status != economy.ProposalPending || !cp.DecidedAt.IsZero() {
+		return invalid("proposal %s arrives decided", cp.ID)
+	}
+	return nil
+}
+
+func validateEndorsement(v categoryEndorsement, now time.Time) error {
+	if v.ProposalID == "" || v.CitizenID == "" {
+		return invalid("endorsement without proposal or citizen")
+	}
+	if err := validateTime("endorsement time", v.EndorsedAt, now); err != nil {
+		return err
+	}
+	// the window of a proposal holds for the time votes are received, a late
+	// vote cannot be backdated into it
+	if v.EndorsedAt.Before(now.Add(-maxClockSkew)) {
+		return invalid("endorsement of %s is dated %s", v.CitizenID, v.EndorsedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// rejections counts the messages of peers refused per reason.
+type rejections map[string]int
+
+func (r rejections) add(err error) {
+	for _, reason := range []error{errMalformed, errUnsupportedVersion, errUnknownType, errInvalid, errUnverified, errRateLimited} {
+		if errors.Is(err, reason) {
+			r[reason.Error()]++
+			return
+		}
+	}
+	r[errInvalid.Error()]++
+}
+
+func (r rejections) total() int {
+	n := 0
+	for _, v := range r {
+		n += v
+	}
+	return n
+}
+
+func (r rejections) String() string {
+	reasons := make([]string, 0, len(r))
+	for reason, n := range r {
+		reasons = append(reasons, reason+": "+strconv.Itoa(n))
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
+
+// reject counts a message of a peer refused by decodeMessage. Peers keep
+// running whatever they receive.
+func (p *pubsub) reject(err error) {
+	p.rejected.add(err)
+	log.Println("Rejected message: " + err.Error())
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+func TestDecodeProposal(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tax := economy.DefaultTaxonomy()
+	k := economy.NewKeyring()
+	key := generateKey()
+	cp := economy.NewCategoryProposal("01HQ", economy.Category{ID: "energy", Name: "Energy"}, economy.Handle(key.Public().(ed25519.PublicKey)), now)
+	if err := cp.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := newEnvelope(messageProposal, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, tax, k, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// one peer cannot bring the quorum along with its proposal
+	for _, id := range []string{"bob", "carol"} {
+		cp.Endorsements = append(cp.Endorsements, economy.Endorsement{CitizenID: id, EndorsedAt: now})
+	}
+	data, err = newEnvelope(messageProposal, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, tax, k, now); !errors.Is(err, errInvalid) {
+		t.Fatalf("got %v, want errInvalid", err)
+	}
+
+	// nor propose in the name of another citizen
+	forged := economy.NewCategoryProposal("01HR", economy.Category{ID: "energy", Name: "Energy"}, "alice", now)
+	if err := forged.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	data, err = newEnvelope(messageProposal, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, tax, k, now); !errors.Is(err, errUnverified) {
+		t.Fatalf("got %v, want errUnverified", err)
+	}
+}
+
+func TestDecodeOffer(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tax := economy.DefaultTaxonomy()
+	k := economy.NewKeyring()
+	key := generateKey()
+	o := economy.Offer{
+		ID:        "01HR",
+		CitizenID: economy.Handle(key.Public().(ed25519.PublicKey)),
+		Category:  "water",
+		Quantity:  economy.Quantity{Amount: 20, Unit: "litres"},
+		CreatedAt: now,
+	}
+	if err := o.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+
+	// another citizen overwriting the offer with a newer version
+	forged := o
+	forged.CitizenID = "bob"
+	forged.Version++
+	if err := forged.Sign(generateKey()); err != nil {
+		t.Fatal(err)
+	}
+	unsigned := o
+	unsigned.Signature = nil
+	for _, o := range []economy.Offer{forged, unsigned} {
+		data, err := newEnvelope(messageOffer, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeMessage(data, tax, k, now); !errors.Is(err, errUnverified) {
+			t.Fatalf("offer of %s: got %v, want errUnverified", o.CitizenID, err)
+		}
+	}
+}
+
+func TestDecodeOldVersions(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, data := range validMessages(t, now) {
+		env := envelope{}
+		if err := decodeStrict(data, &env); err != nil {
+			t.Fatal(err)
+		}
+		// version 1 and the envelopes without a version
+		for _, v := range []int{1, 0} {
+			env.Version = v
+			old, err := json.Marshal(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := decodeMessage(old, economy.DefaultTaxonomy(), economy.NewKeyring(), now); !errors.Is(err, errUnsupportedVersion) {
+				t.Fatalf("%s: got %v, want errUnsupportedVersion", old, err)
+			}
+		}
+	}
+}
+
+func TestDecodeEndorsement(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tax := economy.DefaultTaxonomy()
+	k := economy.NewKeyring()
+	v := categoryEndorsement{ProposalID: "01HQ", Endorsement: economy.NewEndorsement(generateKey(), "01HQ", now)}
+
+	data, err := newEnvelope(messageEndorse, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, tax, k, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// a vote signed after the window of the proposal but dated within it
+	late := categoryEndorsement{ProposalID: "01HQ", Endorsement: economy.NewEndorsement(generateKey(), "01HQ", now.Add(-time.Hour))}
+	data, err = newEnvelope(messageEndorse, late)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, tax, k, now); !errors.Is(err, errInvalid) {
+		t.Fatalf("backdated endorsement: got %v, want errInvalid", err)
+	}
+
+	forged := v
+	forged.CitizenID = "bob"
+	unsigned := categoryEndorsement{ProposalID: "01HQ", Endorsement: economy.Endorsement{CitizenID: "bob", EndorsedAt: now}}
+	for _, v := range []categoryEndorsement{forged, unsigned} {
+		data, err := newEnvelope(messageEndorse, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeMessage(data, tax, k, now); !errors.Is(err, errUnverified) {
+			t.Fatalf("endorsement of %s: got %v, want errUnverified", v.CitizenID, err)
+		}
+	}
+}
+
+// validMessages returns a valid envelope of every message type.
+func validMessages(t testing.TB, now time.Time) [][]byte {
+	key, next := generateKey(), generateKey()
+	citizenID := economy.Handle(key.Public().(ed25519.PublicKey))
+
+	d := economy.Request{
+		ID:        "01HQ",
+		CitizenID: citizenID,
+		Category:  "water",
+		Quantity:  economy.Quantity{Amount: 10, Unit: "litres"},
+		CreatedAt: now,
+	}
+	if err := d.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	c := supplyClaim{RequestID: d.ID, Supplier: citizenID, Amount: 5, ClaimedAt: now}
+	c.sign(key)
+	r := claimRejection{Claim: c, Requester: economy.Handle(next.Public().(ed25519.PublicKey)), RejectedAt: now}
+	r.sign(next)
+	o := economy.Offer{
+		ID:        "01HR",
+		CitizenID: citizenID,
+		Category:  "water",
+		Quantity:  economy.Quantity{Amount: 20, Unit: "litres"},
+		CreatedAt: now,
+	}
+	if err := o.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	cp := economy.NewCategoryProposal("01HS", economy.Category{ID: "energy", Name: "Energy", Unit: "kWh"}, citizenID, now)
+	if err := cp.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	v := categoryEndorsement{ProposalID: cp.ID, Endorsement: economy.NewEndorsement(key, cp.ID, now)}
+
+	msgs := [][]byte{}
+	for _, m := range []struct {
+		typ     string
+		payload any
+	}{
+		{messageDemand, d},
+		{messageConfirm, d},
+		{messageClaim, c},
+		{messageReject, r},
+		{messageOffer, o},
+		{messageProposal, cp},
+		{messageEndorse, v},
+		{messageRotation, economy.NewRotation(key, next, now)},
+		{messageVouch, economy.NewVouch(next, citizenID, now)},
+		{messageShortage, economy.Shortage{Category: "water", Description: "Global shortage of water! "}},
+	} {
+		data, err := newEnvelope(m.typ, m.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, data)
+	}
+	return msgs
+}
+
+func TestDecodeValidMessages(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, data := range validMessages(t, now) {
+		if _, err := decodeMessage(data, economy.DefaultTaxonomy(), economy.NewKeyring(), now); err != nil {
+			t.Errorf("%s: %v", data, err)
+		}
+	}
+}
+
+func FuzzDecodeMessage(f *testing.F) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, data := range validMessages(f, now) {
+		f.Add(data)
+	}
+	f.Add([]byte(`{"type":"demand","version":2,"payload":"5 litres"}`))
+	f.Add([]byte(`{"ID":"01HQ"}`))
+	tax := economy.DefaultTaxonomy()
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := decodeMessage(data, tax, economy.NewKeyring(), now)
+		if err == nil {
+			return
+		}
+		for _, reason := range []error{errMalformed, errUnsupportedVersion, errUnknownType, errInvalid, errUnverified} {
+			if errors.Is(err, reason) {
+				return
+			}
+		}
+		t.Fatalf("error without rejection reason: %v", err)
+	})
+}
+
+func TestDecodeShortage(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, err := newEnvelope(messageShortage, economy.Shortage{Category: "unobtainium"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeMessage(data, economy.DefaultTaxonomy(), economy.NewKeyring(), now); !errors.Is(err, errInvalid) {
+		t.Fatalf("got %v, want errInvalid", err)
+	}
+	// the bare records published before the envelope
+	if _, err := decodeMessage([]byte(`{"Category":"water","Description":"Global shortage of water! "}`), economy.DefaultTaxonomy(), economy.NewKeyring(), now); !errors.Is(err, errMalformed) {
+		t.Fatalf("got %v, want errMalformed", err)
+	}
+}
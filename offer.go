@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+const dbNameSupplyOffers = "supply_offers"
+
+const topicSupply = "supply"
+
+// messageOffer carries a new or updated economy.Offer record on topicSupply.
+//
+// The owner of an offer is its only writer. It runs the matcher against every
+// pending demand it sees and supplies them from the offer through the regular
+// claim protocol, so the demand records a contribution with the OfferID and the
+// offer records the match once the requester confirms it.
+const messageOffer = "offer"
+
+func reservationKey(offerID, requestID string) string {
+	return offerID + "/" + requestID
+}
+
+// reserved returns the amount of an offer held by claims awaiting confirmation.
+func (p *pubsub) reserved(offerID string) float64 {
+	var total float64
+	for k, v := range p.reservations {
+		if strings.HasPrefix(k, offerID+"/") {
+			total += v
+		}
+	}
+	return total
+}
+
+// matchOffers supplies a pending demand from our open offers, oldest first.
+func (p *pubsub) matchOffers(ctx app.Context, d economy.Request) {
+	need := d.Remaining()
+	for _, id := range p.offerIndex {
+		if need <= 0 {
+			return
+		}
+		o := p.offers[id]
+		if !p.mine(o.CitizenID) {
+			continue
+		}
+		if _, ok := p.reservations[reservationKey(o.ID, d.ID)]; ok {
+			continue
+		}
+		amount := economy.MatchAmount(o, p.reserved(o.ID), d, p.taxonomy, time.Now())
+		if amount > need {
+			amount = need
+		}
+		if amount <= 0 {
+			continue
+		}
+		p.claimFromOffer(ctx, o, d, amount)
+		need -= amount
+	}
+}
+
+// matchDemands supplies the pending demands, oldest first, from one of our
+// offers.
+func (p *pubsub) matchDemands(ctx app.Context, offerID string) {
+	for _, id := range p.market.IDs() {
+		o := p.offers[offerID]
+		if _, ok := p.reservations[reservationKey(o.ID, id)]; ok {
+			continue
+		}
+		amount := economy.MatchAmount(o, p.reserved(o.ID), p.market.Request(id), p.taxonomy, time.Now())
+		if amount <= 0 {
+			continue
+		}
+		p.claimFromOffer(ctx, o, p.market.Request(id), amount)
+	}
+}
+
+func (p *pubsub) claimFromOffer(ctx app.Context, o economy.Offer, d economy.Request, amount float64) {
+	// reservations are held in the unit of the offer
+	reserved, err := economy.ConvertAmount(amount, d.Quantity.Unit, o.Quantity.Unit)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	p.reservations[reservationKey(o.ID, d.ID)] = reserved
+	c := supplyClaim{
+		RequestID: d.ID,
+		Version:   d.Version,
+		Supplier:  p.citizenID,
+		Amount:    amount,
+		OfferID:   o.ID,
+		ClaimedAt: time.Now(),
+	}
+	c.sign(p.key)
+	ctx.Async(func() {
+		err := publishMessage(p.transport, p.topic, messageClaim, c)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+// recordMatches marks our offers with the contributions a confirmed demand
+// record holds from them.
+func (p *pubsub) recordMatches(ctx app.Context, d economy.Request) {
+	for _, c := range d.Contributions {
+		o, ok := p.offers[c.OfferID]
+		if !ok || !p.mine(o.CitizenID) || o.Matched(d.ID) {
+			continue
+		}
+		delete(p.reservations, reservationKey(o.ID, d.ID))
+		// contributions are in the unit of the demand
+		amount, err := economy.ConvertAmount(c.Amount, d.Quantity.Unit, o.Quantity.Unit)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		o.Matches = append(o.Matches, economy.OfferMatch{
+			RequestID: d.ID,
+			Amount:    amount,
+			MatchedAt: c.SuppliedAt,
+		})
+		o.Version++
+		if err := o.Sign(p.key); err != nil {
+			log.Fatal(err)
+		}
+		p.offers[o.ID] = o
+		p.storeOffer(ctx, o)
+		p.createNotification(ctx, NotificationSuccess, "Offer matched!", "Your offer supplied "+economy.Quantity{Amount: amount, Unit: o.Quantity.Unit}.String()+" of "+o.Category+".")
+	}
+}
+
+// releaseReservation frees the amount held by a rejected claim.
+func (p *pubsub) releaseReservation(c supplyClaim) {
+	delete(p.reservations, reservationKey(c.OfferID, c.RequestID))
+}
+
+// storeOffer persists and publishes an offer.
+func (p *pubsub) storeOffer(ctx app.Context, o economy.Offer) {
+	ctx.Async(func() {
+		offer, err := json.Marshal(o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// store in orbit-db first
+		err = p.ledger.Put(dbNameSupplyOffers, o.ID, offer)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = publishMessage(p.transport, topicSupply, messageOffer, o)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+// applyOffer stores an offer received from a peer unless a newer version is
+// already known. Only the citizen of an offer updates it.
+func (p *pubsub) applyOffer(o economy.Offer) bool {
+	if cur, ok := p.offers[o.ID]; ok && (cur.Version > o.Version || p.keys.Latest(cur.CitizenID) != p.keys.Latest(o.CitizenID)) {
+		return false
+	}
+	p.offers[o.ID] = o
+	p.offerIndex = economy.InsertID(p.offerIndex, o.ID)
+	return true
+}
+
+func (p *pubsub) onOfferInput(ctx app.Context, e app.Event) {
+	v := strings.TrimSpace(ctx.JSSrc().Get("value").String())
+	switch ctx.JSSrc().Get("name").String() {
+	case "offer-category":
+		p.offerForm.Category = v
+	case "offer-quantity":
+		amount, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			amount = 0
+		}
+		p.offerForm.Quantity.Amount = amount
+	case "offer-unit":
+		p.offerForm.Quantity.Unit = v
+	case "offer-location":
+		p.offerForm.Location = v
+	case "offer-until":
+		until, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			p.offerForm.AvailableUntil = time.Time{}
+			return
+		}
+		// available until the end of the day
+		p.offerForm.AvailableUntil = until.AddDate(0, 0, 1).Add(-time.Second)
+	}
+}
+
+func (p *pubsub) publishOffer(ctx app.Context, e app.Event) {
+	o := p.offerForm
+	if o.Category == "" || o.Quantity.Amount <= 0 {
+		p.createNotification(ctx, NotificationWarning, "Incomplete offer!", "Select a category and a quantity to offer.")
+		return
+	}
+	o.CitizenID = p.citizenID
+	o.CreatedAt = time.Now()
+	o.ID = newRequestID(o.CreatedAt)
+	if o.Quantity.Unit == "" {
+		o.Quantity.Unit = p.taxonomy.Unit(o.Category)
+	}
+	if err := o.Quantity.Validate(); err != nil {
+		p.createNotification(ctx, NotificationWarning, "Invalid quantity!", unitHint(err))
+		return
+	}
+	o.Quantity = o.Quantity.Normalize()
+	o.Matches = nil
+	o.Version = 0
+	if err := o.Sign(p.key); err != nil {
+		log.Fatal(err)
+	}
+
+	p.applyOffer(o)
+	p.storeOffer(ctx, o)
+	p.createNotification(ctx, NotificationSuccess, "Offer published!", "You offer "+o.Quantity.String()+" of "+o.Category+".")
+	p.matchDemands(ctx, o.ID)
+}
+
+// openOffers returns the IDs of the offers still available, newest first.
+func (p *pubsub) openOffers() []string {
+	ids := []string{}
+	now := time.Now()
+	for _, id := range p.offerIndex {
+		if p.offers[id].Open(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids
+}
+
+func (p *pubsub) FetchAllOffers(ctx app.Context) {
+	ctx.Async(func() {
+		os, err := p.ledger.List(dbNameSupplyOffers)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		offers := make(map[string]economy.Offer, len(os))
+		malformed := []error{}
+		for key, v := range os {
+			o := economy.Offer{}
+			err = json.Unmarshal(v, &o)
+			if err != nil {
+				malformed = append(malformed, malformedRecord(dbNameSupplyOffers, key, err))
+				continue
+			}
+			offers[o.ID] = o
+		}
+
+		ctx.Dispatch(func(ctx app.Context) {
+			for _, err := range malformed {
+				p.rejected.add(err)
+			}
+			now := time.Now()
+			for _, o := range offers {
+				if err := validateOffer(o, p.taxonomy, now); err != nil {
+					p.rejected.add(err)
+					continue
+				}
+				if err := o.Verify(p.keys); err != nil {
+					p.rejected.add(verified(err))
+					continue
+				}
+				p.applyOffer(o)
+			}
+		})
+	})
+}
+
+func (p *pubsub) subscribeOffers(ctx app.Context) {
+	ctx.Async(func() {
+		subscription, err := p.transport.Subscribe(topicSupply)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.offerSub = subscription
+		p.offerSubscription(ctx)
+	})
+}
+
+func (p *pubsub) offerSubscription(ctx app.Context) {
+	ctx.Async(func() {
+		// wait on pubsub
+		res, err := p.offerSub.Next()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx.Async(func() {
+			p.offerSubscription(ctx)
+		})
+		ctx.Dispatch(func(ctx app.Context) {
+			m, err := decodeMessage(res.Data, p.taxonomy, p.keys, time.Now())
+			if err != nil {
+				p.reject(err)
+				return
+			}
+			if m.Type != messageOffer {
+				p.reject(fmt.Errorf("%w: %q on topic %s", errUnknownType, m.Type, topicSupply))
+				return
+			}
+			p.applyOffer(m.Payload.(economy.Offer))
+		})
+	})
+}
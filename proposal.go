@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// dbNameCategoryProposals holds the proposals of new categories with their
+// endorsements and outcome, see economy.CategoryProposal.
+const dbNameCategoryProposals = "category_proposals"
+
+const topicCategories = "categories"
+
+// Message types published on topicCategories.
+const (
+	// messageProposal carries a new economy.CategoryProposal.
+	messageProposal = "proposal"
+	// messageEndorse carries a categoryEndorsement.
+	messageEndorse = "endorse"
+)
+
+// categoryEndorsement is the signed vote of a citizen for a proposal. Every
+// peer counts the votes and adopts the category once the quorum is reached.
+type categoryEndorsement struct {
+	ProposalID string
+	economy.Endorsement
+}
+
+// applyProposal records a new proposal of a peer. Proposals are published
+// without endorsements, the votes of a known proposal only count when received
+// on their own.
+func (p *pubsub) applyProposal(ctx app.Context, cp economy.CategoryProposal) {
+	if _, ok := p.proposals[cp.ID]; ok {
+		return
+	}
+	p.decideProposal(ctx, cp)
+}
+
+// applyEndorsement counts a vote received from a peer.
+func (p *pubsub) applyEndorsement(ctx app.Context, v categoryEndorsement) {
+	cp, ok := p.proposals[v.ProposalID]
+	if !ok || !cp.Endorse(v.Endorsement) {
+		return
+	}
+	p.decideProposal(ctx, cp)
+}
+
+// decideProposal records a proposal and adopts its category once it reaches
+// the quorum.
+func (p *pubsub) decideProposal(ctx app.Context, cp economy.CategoryProposal) {
+	changed := cp.Decide(time.Now())
+	p.proposals[cp.ID] = cp
+	if !changed || cp.Status != economy.ProposalAdopted {
+		return
+	}
+	if p.adoptCategory(cp) {
+		p.createNotification(ctx, NotificationSuccess, "New category!", cp.Category.Name+" has been adopted by the community.")
+	}
+}
+
+// adoptCategory adds the category of an adopted proposal to the taxonomy. It
+// reports whether the category is new.
+func (p *pubsub) adoptCategory(cp economy.CategoryProposal) bool {
+	if _, ok := p.taxonomy.Get(cp.Category.ID); ok {
+		return false
+	}
+	if err := p.taxonomy.Add(cp.Category); err != nil {
+		log.Println("Ignoring adopted category: " + err.Error())
+		return false
+	}
+	p.market.SetTaxonomy(p.taxonomy)
+	p.matches = p.bestMatches()
+	p.filteredRequests = p.market.Category(p.category)
+	return true
+}
+
+// adoptCategories adds the categories of every adopted proposal to the
+// taxonomy, parents first as they are adopted first.
+func (p *pubsub) adoptCategories() {
+	adopted := []economy.CategoryProposal{}
+	for _, cp := range p.proposals {
+		if cp.Status == economy.ProposalAdopted {
+			adopted = append(adopted, cp)
+		}
+	}
+	sort.Slice(adopted, func(i, j int) bool {
+		return adopted[i].DecidedAt.Before(adopted[j].DecidedAt)
+	})
+	for _, cp := range adopted {
+		p.adoptCategory(cp)
+	}
+}
+
+// pendingProposals returns the proposals open for endorsement, oldest first.
+func (p *pubsub) pendingProposals() []economy.CategoryProposal {
+	res := []economy.CategoryProposal{}
+	for _, cp := range p.proposals {
+		if cp.Status == economy.ProposalPending {
+			res = append(res, cp)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// storeProposal persists our copy of a proposal merged with the stored one, so
+// the endorsements recorded by other peers are kept. Only the endorsements
+// signed by the keys of their citizens are merged.
+func (p *pubsub) storeProposal(ctx app.Context, id string) {
+	ctx.Async(func() {
+		v, err := p.ledger.Get(dbNameCategoryProposals, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			log.Fatal(err)
+		}
+		stored := economy.CategoryProposal{}
+		if err == nil {
+			err = json.Unmarshal(v, &stored)
+		}
+
+		// the keyring is only used on the UI goroutine
+		ctx.Dispatch(func(ctx app.Context) {
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				// overwritten by our copy
+				p.rejected.add(malformedRecord(dbNameCategoryProposals, id, err))
+			}
+			cp := p.proposals[id]
+			cp.Merge(stored, p.keys)
+			p.decideProposal(ctx, cp)
+			cp = p.proposals[id]
+
+			ctx.Async(func() {
+				proposal, err := json.Marshal(cp)
+				if err != nil {
+					log.Fatal(err)
+				}
+				err = p.ledger.Put(dbNameCategoryProposals, cp.ID, proposal)
+				if err != nil {
+					log.Fatal(err)
+				}
+			})
+		})
+	})
+}
+
+func (p *pubsub) onProposalInput(ctx app.Context, e app.Event) {
+	v := strings.TrimSpace(ctx.JSSrc().Get("value").String())
+	switch ctx.JSSrc().Get("name").String() {
+	case "proposal-name":
+		p.proposalForm.Name = v
+		p.proposalForm.ID = strings.ToLower(strings.Join(strings.Fields(v), "-"))
+	case "proposal-parent":
+		p.proposalForm.Parent = v
+	case "proposal-unit":
+		p.proposalForm.Unit = v
+	}
+}
+
+func (p *pubsub) proposeCategory(ctx app.Context, e app.Event) {
+	c := p.proposalForm
+	if c.ID == "" {
+		p.createNotification(ctx, NotificationWarning, "Incomplete proposal!", "Name the category you propose.")
+		return
+	}
+	_, exists := p.taxonomy.Get(c.ID)
+	for _, cp := range p.pendingProposals() {
+		exists = exists || cp.Category.ID == c.ID
+	}
+	if exists {
+		p.createNotification(ctx, NotificationWarning, "Already there!", c.Name+" is already a category or proposed.")
+		return
+	}
+
+	now := time.Now()
+	cp := economy.NewCategoryProposal(newRequestID(now), c, p.citizenID, now)
+	if err := cp.Sign(p.key); err != nil {
+		log.Fatal(err)
+	}
+	v := categoryEndorsement{
+		ProposalID:  cp.ID,
+		Endorsement: economy.NewEndorsement(p.key, cp.ID, now),
+	}
+	published := cp
+	cp.Endorse(v.Endorsement)
+	p.decideProposal(ctx, cp)
+	p.storeProposal(ctx, cp.ID)
+	ctx.Async(func() {
+		// the proposer endorses its proposal like any other citizen
+		err := publishMessage(p.transport, topicCategories, messageProposal, published)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = publishMessage(p.transport, topicCategories, messageEndorse, v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+	p.createNotification(ctx, NotificationSuccess, "Category proposed!", c.Name+" is adopted once "+strconv.Itoa(economy.ProposalQuorum)+" citizens endorse it.")
+}
+
+func (p *pubsub) endorseCategory(ctx app.Context, e app.Event) {
+	cp, ok := p.proposals[ctx.JSSrc().Get("value").String()]
+	if !ok {
+		return
+	}
+	v := categoryEndorsement{
+		ProposalID:  cp.ID,
+		Endorsement: economy.NewEndorsement(p.key, cp.ID, time.Now()),
+	}
+	if !cp.Endorse(v.Endorsement) {
+		return
+	}
+	p.decideProposal(ctx, cp)
+	p.storeProposal(ctx, cp.ID)
+	ctx.Async(func() {
+		err := publishMessage(p.transport, topicCategories, messageEndorse, v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+// FetchAllProposals loads the stored proposals, adopts the categories of the
+// adopted ones and then calls loaded.
+func (p *pubsub) FetchAllProposals(ctx app.Context, loaded func(ctx app.Context)) {
+	ctx.Async(func() {
+		ps, err := p.ledger.List(dbNameCategoryProposals)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		proposals := make([]economy.CategoryProposal, 0, len(ps))
+		malformed := []error{}
+		for key, v := range ps {
+			cp := economy.CategoryProposal{}
+			err = json.Unmarshal(v, &cp)
+			if err != nil {
+				malformed = append(malformed, malformedRecord(dbNameCategoryProposals, key, err))
+				continue
+			}
+			proposals = append(proposals, cp)
+		}
+
+		ctx.Dispatch(func(ctx app.Context) {
+			for _, err := range malformed {
+				p.rejected.add(err)
+			}
+			for _, stored := range proposals {
+				if err := stored.Verify(p.keys); err != nil {
+					p.rejected.add(verified(err))
+					continue
+				}
+				// the stored endorsements and outcome are not trusted
+				cp := stored.Verified(p.keys)
+				if cur, ok := p.proposals[cp.ID]; ok {
+					cp.Merge(cur, p.keys)
+				}
+				cp.Decide(time.Now())
+				p.proposals[cp.ID] = cp
+			}
+			p.adoptCategories()
+			loaded(ctx)
+		})
+	})
+}
+
+func (p *pubsub) subscribeProposals(ctx app.Context) {
+	ctx.Async(func() {
+		subscription, err := p.transport.Subscribe(topicCategories)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.proposalSub = subscription
+		p.proposalSubscription(ctx)
+	})
+}
+
+func (p *pubsub) proposalSubscription(ctx app.Context) {
+	ctx.Async(func() {
+		// wait on pubsub
+		res, err := p.proposalSub.Next()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx.Async(func() {
+			p.proposalSubscription(ctx)
+		})
+		ctx.Dispatch(func(ctx app.Context) {
+			m, err := decodeMessage(res.Data, p.taxonomy, p.keys, time.Now())
+			if err != nil {
+				p.reject(err)
+				return
+			}
+
+			switch m.Type {
+			case messageProposal:
+				p.applyProposal(ctx, m.Payload.(economy.CategoryProposal))
+			case messageEndorse:
+				p.applyEndorsement(ctx, m.Payload.(categoryEndorsement))
+			default:
+				p.reject(fmt.Errorf("%w: %q on topic %s", errUnknownType, m.Type, topicCategories))
+			}
+		})
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// relayPath is where main serves the WebSocket relay.
+const relayPath = "/relay"
+
+// relayQueue is the most frames waiting for a peer of the relay. Peers too
+// slow to keep up are disconnected instead of holding up the others.
+const relayQueue = 256
+
+// Bounds of the time between two reconnections to the relay.
+const (
+	relayMinBackoff = time.Second
+	relayMaxBackoff = 30 * time.Second
+)
+
+// relayFrame is the unit exchanged with the relay. The relay forwards every
+// frame to all connected peers, the sender included, and peers filter on topic.
+// From is set by the relay to the ID of the sending connection. The first
+// frame of a connection has no topic and tells the peer its ID.
+type relayFrame struct {
+	Topic string `json:"topic"`
+	From  string `json:"from"`
+	Data  []byte `json:"data"`
+}
+
+// relay is a minimal pubsub server for playing in several browser tabs on one
+// machine without an IPFS daemon.
+type relay struct {
+	mu    sync.Mutex
+	peers map[*relayPeer]bool
+}
+
+// relayPeer is a connection to the relay with its queue of frames to send.
+type relayPeer struct {
+	id    string
+	conn  *websocket.Conn
+	queue chan []byte
+}
+
+func newRelay() *relay {
+	return &relay{peers: make(map[*relayPeer]bool)}
+}
+
+func (r *relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	conn, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.CloseNow()
+
+	peer := &relayPeer{id: newPeerID(), conn: conn, queue: make(chan []byte, relayQueue)}
+	hello, err := json.Marshal(relayFrame{From: peer.id})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	peer.queue <- hello
+
+	r.mu.Lock()
+	r.peers[peer] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.peers, peer)
+		r.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	go peer.write(ctx)
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		f := relayFrame{}
+		if err := json.Unmarshal(data, &f); err != nil || f.Topic == "" {
+			continue
+		}
+		// peers cannot speak for each other
+		f.From = peer.id
+		data, err = json.Marshal(f)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		r.broadcast(data)
+	}
+}
+
+// write sends the queued frames of the peer until ctx is done.
+func (p *relayPeer) write(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-p.queue:
+			if err := p.conn.Write(ctx, websocket.MessageText, data); err != nil {
+				p.conn.CloseNow()
+				return
+			}
+		}
+	}
+}
+
+// broadcast queues a frame for every peer, dropping the ones whose queue is
+// full.
+func (r *relay) broadcast(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.peers {
+		select {
+		case p.queue <- data:
+		default:
+			log.Println("relay: dropping slow peer", p.id)
+			delete(r.peers, p)
+			// closing waits for the peer, which is slow
+			go p.conn.Close(websocket.StatusPolicyViolation, "too slow")
+		}
+	}
+}
+
+// relayTransport publishes through the relay served by main. It reconnects
+// when the connection is lost; the messages published meanwhile are lost.
+type relayTransport struct {
+	topicSubscribers
+	url  string
+	mu   sync.Mutex
+	id   string
+	conn *websocket.Conn
+}
+
+func newRelayTransport(url string) (*relayTransport, error) {
+	t := &relayTransport{url: url}
+	if err := t.connect(); err != nil {
+		return nil, err
+	}
+	go t.read()
+	return t, nil
+}
+
+// connect dials the relay and waits for the ID it gives to the connection.
+func (t *relayTransport) connect() error {
+	conn, _, err := websocket.Dial(context.Background(), t.url, nil)
+	if err != nil {
+		return err
+	}
+	_, data, err := conn.Read(context.Background())
+	if err != nil {
+		conn.CloseNow()
+		return err
+	}
+	hello := relayFrame{}
+	if err := json.Unmarshal(data, &hello); err != nil || hello.From == "" {
+		conn.CloseNow()
+		return errors.New("relay: missing peer ID")
+	}
+	t.mu.Lock()
+	t.id, t.conn = hello.From, conn
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *relayTransport) read() {
+	backoff := relayMinBackoff
+	for {
+		t.mu.Lock()
+		conn := t.conn
+		t.mu.Unlock()
+		_, data, err := conn.Read(context.Background())
+		if err != nil {
+			log.Println(err)
+			conn.CloseNow()
+			for t.connect() != nil {
+				time.Sleep(backoff)
+				backoff = min(2*backoff, relayMaxBackoff)
+			}
+			backoff = relayMinBackoff
+			continue
+		}
+		f := relayFrame{}
+		if err := json.Unmarshal(data, &f); err != nil {
+			log.Println(err)
+			continue
+		}
+		t.deliver(f.Topic, &Message{From: f.From, Data: f.Data})
+	}
+}
+
+func (t *relayTransport) ID() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.id, nil
+}
+
+func (t *relayTransport) Publish(topic string, data []byte) error {
+	f, err := json.Marshal(relayFrame{Topic: topic, Data: data})
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	conn := t.conn
+	t.mu.Unlock()
+	return conn.Write(context.Background(), websocket.MessageText, f)
+}
+
+func (t *relayTransport) Subscribe(topic string) (Subscription, error) {
+	return t.add(topic), nil
+}
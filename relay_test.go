@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// next returns the next message of sub, failing after a while.
+func next(t *testing.T, sub Subscription) *Message {
+	t.Helper()
+	res := make(chan *Message, 1)
+	go func() {
+		m, err := sub.Next()
+		if err != nil {
+			t.Error(err)
+		}
+		res <- m
+	}()
+	select {
+	case m := <-res:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return nil
+}
+
+func relayURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestRelaySetsSender(t *testing.T) {
+	srv := httptest.NewServer(newRelay())
+	defer srv.Close()
+
+	alice, err := newRelayTransport(relayURL(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := newRelayTransport(relayURL(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := bob.Subscribe(topicDemand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceID, _ := alice.ID()
+	bobID, _ := bob.ID()
+	if aliceID == "" || aliceID == bobID {
+		t.Fatalf("peer IDs %q and %q", aliceID, bobID)
+	}
+
+	// a frame claiming to come from bob is relayed as coming from alice
+	f, err := json.Marshal(relayFrame{Topic: topicDemand, From: bobID, Data: []byte("spoofed")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.conn.Write(context.Background(), websocket.MessageText, f); err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, sub); m.From != aliceID || string(m.Data) != "spoofed" {
+		t.Fatalf("got %q from %q, want it from %q", m.Data, m.From, aliceID)
+	}
+}
+
+func TestRelaySlowPeer(t *testing.T) {
+	srv := httptest.NewServer(newRelay())
+	defer srv.Close()
+
+	// a peer which never reads
+	slow, _, err := websocket.Dial(context.Background(), relayURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.CloseNow()
+
+	alice, err := newRelayTransport(relayURL(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := alice.Subscribe(topicDemand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("x", 4096))
+	for i := 0; i < 2*relayQueue; i++ {
+		if err := alice.Publish(topicDemand, data); err != nil {
+			t.Fatal(err)
+		}
+		next(t, sub)
+	}
+}
+
+func TestRelayReconnects(t *testing.T) {
+	srv := httptest.NewServer(newRelay())
+	defer srv.Close()
+
+	alice, err := newRelayTransport(relayURL(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := alice.Subscribe(topicDemand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := alice.ID()
+	alice.mu.Lock()
+	alice.conn.CloseNow()
+	alice.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for id, _ := alice.ID(); id == old; id, _ = alice.ID() {
+		if time.Now().After(deadline) {
+			t.Fatal("not reconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := alice.Publish(topicDemand, []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, sub); string(m.Data) != "again" {
+		t.Fatalf("got %q", m.Data)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// newRequestID returns a globally unique request ID with 80 random bits of
+// entropy, see economy.RequestID. Peers can generate them concurrently without
+// coordination.
+func newRequestID(t time.Time) string {
+	var entropy [10]byte
+	if _, err := rand.Read(entropy[:]); err != nil {
+		panic(err)
+	}
+	return economy.RequestID(t, entropy)
+}
+
+// legacyRequestID maps a sequential integer ID of a request created before
+// ULIDs to a request ID. The entropy is derived from the integer so every peer
+// migrating the same record ends up with the same key.
+func legacyRequestID(n int, createdAt time.Time) string {
+	var entropy [10]byte
+	binary.BigEndian.PutUint64(entropy[2:], uint64(n))
+	return economy.RequestID(createdAt, entropy)
+}
+
+// migrateLegacyRequests rewrites the records stored under sequential integer
+// keys to their request ID, both in the ledger and in the returned records.
+// Records that do not decode are left out of the returned records, and
+// returned as malformed.
+func migrateLegacyRequests(l Ledger, records map[string][]byte) (map[string][]byte, []error, error) {
+	malformed := []error{}
+	for key, data := range records {
+		n, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+
+		// the integer ID shadows the string one of the embedded request
+		legacy := struct {
+			ID int
+			economy.Request
+		}{}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			malformed = append(malformed, malformedRecord(dbNameSupplyDemand, key, err))
+			delete(records, key)
+			continue
+		}
+		d := legacy.Request
+		d.ID = legacyRequestID(n, d.CreatedAt)
+
+		migrated, err := json.Marshal(d)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := l.Put(dbNameSupplyDemand, d.ID, migrated); err != nil {
+			return nil, nil, err
+		}
+		if err := l.Delete(dbNameSupplyDemand, key); err != nil {
+			return nil, nil, err
+		}
+		delete(records, key)
+		records[d.ID] = migrated
+	}
+	return records, malformed, nil
+}
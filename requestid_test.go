@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+func TestNewRequestIDOrder(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ids := []string{}
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		// several IDs per millisecond
+		id := newRequestID(start.Add(time.Duration(i/4) * time.Millisecond))
+		if len(id) != 26 || seen[id] {
+			t.Fatalf("ID %q is not a new ULID", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	// sorted by time, in any order within a millisecond
+	for i := 4; i < len(ids); i++ {
+		if ids[i] <= ids[i/4*4-1] {
+			t.Fatalf("ID %d %s sorts before the IDs of an earlier time", i, ids[i])
+		}
+	}
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	for i := range sorted {
+		if sorted[i][:10] != ids[i][:10] {
+			t.Fatalf("sorting mixes the times: %s, want the time of %s", sorted[i], ids[i])
+		}
+	}
+}
+
+func TestMigrateLegacyRequests(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newMemoryLedger()
+	current := newRequestID(createdAt)
+	records := map[string][]byte{
+		"1":     []byte(`{"ID":1,"CitizenID":"alice","Category":"water","Quantity":"5 litres","CreatedAt":"2024-03-01T12:00:00Z"}`),
+		"2":     []byte(`{"ID":2,"CitizenID":"bob","Category":"food","Quantity":"3","CreatedAt":"2024-03-01T12:00:00Z"}`),
+		"3":     []byte(`{"ID":3,"CitizenID":`),
+		current: []byte(`{"ID":"` + current + `","CitizenID":"carol"}`),
+	}
+	for key, v := range records {
+		if err := l.Put(dbNameSupplyDemand, key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrated, malformed, err := migrateLegacyRequests(l, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(malformed) != 1 || !errors.Is(malformed[0], errMalformed) {
+		t.Fatalf("got malformed %v, want record 3", malformed)
+	}
+	if len(migrated) != 3 {
+		t.Fatalf("got %d records, want 3", len(migrated))
+	}
+	for n, citizen := range map[int]string{1: "alice", 2: "bob"} {
+		id := legacyRequestID(n, createdAt)
+		d := economy.Request{}
+		if err := json.Unmarshal(migrated[id], &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.ID != id || d.CitizenID != citizen {
+			t.Fatalf("record %d migrated to %+v", n, d)
+		}
+		if _, err := l.Get(dbNameSupplyDemand, id); err != nil {
+			t.Fatalf("record %d not stored under %s: %v", n, id, err)
+		}
+	}
+	if _, ok := migrated[current]; !ok {
+		t.Fatal("record with a request ID lost")
+	}
+	if _, err := l.Get(dbNameSupplyDemand, "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("legacy key kept: %v", err)
+	}
+
+	// every peer migrates to the same keys
+	if legacyRequestID(1, createdAt) != legacyRequestID(1, createdAt) || legacyRequestID(1, createdAt) == legacyRequestID(2, createdAt) {
+		t.Fatal("legacy IDs are not derived from the integer")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// scoringKey and halfLifeKey are where the scoring strategy of the rankings
+// and its half-life in days are kept in the browser local storage.
+const (
+	scoringKey  = "scoring"
+	halfLifeKey = "halfLife"
+)
+
+// rank ranks the citizens by their current handle with the scoring strategy,
+// refreshes the suspicious identities and the reputation history.
+func (p *pubsub) rank() []economy.Ranking {
+	requests := p.keys.Canonical(p.market.Signed())
+	p.suspicious = p.sybil.Suspicious(requests)
+	rank := p.ranker()
+	now := time.Now()
+	p.history = economy.History(requests, p.historyCitizen(), historyTimes(p.historyPeriod, now), rank)
+	return rank(requests, now)
+}
+
+// ranker returns the ranking of the dashboard settings.
+func (p *pubsub) ranker() func(requests []economy.Request, at time.Time) []economy.Ranking {
+	var trusted map[string]bool
+	if p.discounted {
+		trusted = p.trusted()
+	}
+	return func(requests []economy.Request, at time.Time) []economy.Ranking {
+		w := p.scoring.Weights(requests, at)
+		if p.discounted {
+			return p.sybil.Rank(requests, w, trusted)
+		}
+		return economy.RankWeighted(requests, w)
+	}
+}
+
+// historyCitizen returns the citizen whose history is shown, the citizen of
+// the dashboard by default.
+func (p *pubsub) historyCitizen() string {
+	if p.historyOf == "" {
+		return p.citizenID
+	}
+	return p.keys.Latest(p.historyOf)
+}
+
+// historyTimes returns the ends of the buckets of a period ending with now,
+// oldest first, the last one being now.
+func historyTimes(period string, now time.Time) []time.Time {
+	buckets, err := economy.EmptyBuckets(period, now, time.Local)
+	if err != nil {
+		log.Println(err)
+	}
+	times := make([]time.Time, 0, len(buckets))
+	for _, b := range buckets {
+		if b.End.After(now) {
+			b.End = now
+		}
+		times = append(times, b.End)
+	}
+	return times
+}
+
+func (p *pubsub) loadScoring(ctx app.Context) {
+	if err := ctx.LocalStorage().Get(scoringKey, &p.scoring.Strategy); err != nil {
+		log.Println(err)
+	}
+	var days float64
+	if err := ctx.LocalStorage().Get(halfLifeKey, &days); err != nil {
+		log.Println(err)
+	}
+	p.scoring.HalfLife = time.Duration(days * float64(24*time.Hour))
+	if err := p.scoring.Validate(); err != nil {
+		p.scoring = economy.Scoring{Strategy: economy.ScoringClassic}
+	}
+}
+
+func (p *pubsub) onScoring(ctx app.Context, e app.Event) {
+	name := ctx.JSSrc().Get("value").String()
+	if _, ok := economy.LookupStrategy(name); !ok {
+		return
+	}
+	p.scoring.Strategy = name
+	if err := ctx.LocalStorage().Set(scoringKey, p.scoring.Strategy); err != nil {
+		log.Println(err)
+	}
+	p.ranks = p.rank()
+}
+
+func (p *pubsub) onHalfLife(ctx app.Context, e app.Event) {
+	days, err := strconv.ParseFloat(ctx.JSSrc().Get("value").String(), 64)
+	if err != nil || days <= 0 {
+		return
+	}
+	p.scoring.HalfLife = time.Duration(days * float64(24*time.Hour))
+	if err := ctx.LocalStorage().Set(halfLifeKey, days); err != nil {
+		log.Println(err)
+	}
+	p.ranks = p.rank()
+}
+
+// halfLifeDays returns the half-life of the decayed scoring in days.
+func (p *pubsub) halfLifeDays() string {
+	d := p.scoring.HalfLife
+	if d == 0 {
+		d = economy.ScoringHalfLife
+	}
+	return strconv.FormatFloat(d.Hours()/24, 'f', -1, 64)
+}
+
+// scoringOptions lists the scoring strategies with the current one selected.
+func (p *pubsub) scoringOptions() []app.UI {
+	opts := []app.UI{}
+	for _, s := range economy.Strategies() {
+		opts = append(opts, app.Option().Value(s.Name).Title(s.Description).Selected(s.Name == p.scoring.Strategy).Text(s.Name+" - "+s.Description))
+	}
+	return opts
+}
+
+// onHistory shows the reputation history of the citizen of the clicked rank.
+func (p *pubsub) onHistory(ctx app.Context, e app.Event) {
+	p.historyOf = ctx.JSSrc().Get("value").String()
+	p.ranks = p.rank()
+}
+
+func (p *pubsub) onHistoryPeriod(ctx app.Context, e app.Event) {
+	period := ctx.JSSrc().Get("value").String()
+	if _, err := economy.EmptyBuckets(period, time.Now(), time.Local); err != nil {
+		return
+	}
+	p.historyPeriod = period
+	p.ranks = p.rank()
+}
+
+// historyPeriodOptions lists the periods of the reputation history.
+func (p *pubsub) historyPeriodOptions() []app.UI {
+	periods := []struct{ value, text string }{
+		{Hour, "Last hour"},
+		{Day, "Last day"},
+		{Week, "Last week"},
+		{Month, "Last month"},
+		{Year, "Last year"},
+	}
+	opts := []app.UI{}
+	for _, o := range periods {
+		opts = append(opts, app.Option().Value(o.value).Selected(o.value == p.historyPeriod).Text(o.text))
+	}
+	return opts
+}
+
+// historySpan returns the time between two points of the history.
+func (p *pubsub) historySpan() time.Duration {
+	if len(p.history) < 2 {
+		return time.Hour
+	}
+	return p.history[1].Time.Sub(p.history[0].Time)
+}
+
+// historyTable renders the reputation history of a citizen.
+func (p *pubsub) historyTable() app.UI {
+	return app.Div().Class("table-responsive mb-2").Body(
+		app.Table().Class("table table-sm table-bordered text-center mb-0").Body(
+			app.Caption().Text("Reputation of "+p.historyCitizen()),
+			app.TBody().Body(
+				app.Tr().Body(
+					app.Range(p.history).Slice(func(i int) app.UI {
+						return app.Th().Scope("col").Body(app.Small().Text(timeLabel(p.history[i].Time, p.historySpan(), false)))
+					}),
+				),
+				app.Tr().Body(
+					app.Range(p.history).Slice(func(i int) app.UI {
+						return app.Td().Body(app.Small().Text(strconv.FormatFloat(p.history[i].ReputationIndex, 'f', 3, 64)))
+					}),
+				),
+			),
+		),
+	)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// sign records the consent of the supplier to the claim.
+func (c *supplyClaim) sign(key ed25519.PrivateKey) {
+	c.Signer = key.Public().(ed25519.PublicKey)
+	c.Signature = ed25519.Sign(key, economy.ConsentMessage(c.RequestID, c.Supplier, c.Amount, c.ClaimedAt, c.OfferID))
+}
+
+// verify checks the consent of the supplier to the claim.
+func (c supplyClaim) verify() error {
+	return c.contribution().Verify(c.RequestID)
+}
+
+// contribution returns the contribution the claim makes to its demand, with
+// the consent of the supplier.
+func (c supplyClaim) contribution() economy.Contribution {
+	return economy.Contribution{
+		Supplier:   c.Supplier,
+		Amount:     c.Amount,
+		SuppliedAt: c.ClaimedAt,
+		OfferID:    c.OfferID,
+		Claimed:    c.Amount,
+		Signer:     c.Signer,
+		Signature:  c.Signature,
+	}
+}
+
+// rejectionMessage is what the requester signs when turning a claim down.
+func rejectionMessage(r claimRejection) []byte {
+	c := r.Claim
+	return append([]byte("reject\n"+r.Requester+"\n"+r.RejectedAt.UTC().Format(time.RFC3339Nano)+"\n"), economy.ConsentMessage(c.RequestID, c.Supplier, c.Amount, c.ClaimedAt, c.OfferID)...)
+}
+
+// sign records the refusal of the claim by the requester.
+func (r *claimRejection) sign(key ed25519.PrivateKey) {
+	r.Signer = key.Public().(ed25519.PublicKey)
+	r.Signature = ed25519.Sign(key, rejectionMessage(*r))
+}
+
+// verify checks the signature of the rejection. It does not tell whether
+// the signer is the requester of the demand.
+func (r claimRejection) verify() error {
+	if len(r.Signer) != ed25519.PublicKeySize || !ed25519.Verify(r.Signer, rejectionMessage(r), r.Signature) {
+		return fmt.Errorf("%w: rejection of a claim on %s", economy.ErrBadSignature, r.Claim.RequestID)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/simulation"
+)
+
+// commands are the subcommands of the server binary.
+var commands = map[string]func(args []string) error{
+	"simulate": runSimulate,
+}
+
+// runSimulate implements `cyber-stasis simulate`, which runs synthetic
+// citizens against the economy model and writes the results for analysis.
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	config := fs.String("config", "", "JSON configuration of the citizens and their profiles, the defaults when empty")
+	citizens := fs.Int("citizens", 0, "number of citizens, overrides the configuration")
+	duration := fs.Duration("duration", 0, "simulated period, overrides the configuration")
+	step := fs.Duration("step", 0, "simulated time between two steps, overrides the configuration")
+	seed := fs.Int64("seed", 0, "random seed, overrides the configuration")
+	scoring := fs.String("scoring", "", "scoring strategy of the rankings, classic, decayed, scarcity or quantity, overrides the configuration")
+	halfLife := fs.Duration("half-life", 0, "half-life of the decayed scoring strategy, overrides the configuration")
+	format := fs.String("format", "csv", "output format, csv or json")
+	out := fs.String("out", "", "directory of the csv files, the current one when empty, or file of the json document, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c := simulation.DefaultConfig()
+	if *config != "" {
+		var err error
+		c, err = simulation.LoadConfig(*config)
+		if err != nil {
+			return err
+		}
+	}
+	if *citizens > 0 {
+		c.Citizens = *citizens
+	}
+	if *duration > 0 {
+		c.Duration = simulation.Duration(*duration)
+	}
+	if *step > 0 {
+		c.Step = simulation.Duration(*step)
+	}
+	if *seed != 0 {
+		c.Seed = *seed
+	}
+	if *scoring != "" {
+		c.Scoring = *scoring
+	}
+	if *halfLife > 0 {
+		c.HalfLife = simulation.Duration(*halfLife)
+	}
+
+	started := time.Now()
+	res, err := simulation.Run(c)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "csv":
+		dir := *out
+		if dir == "" {
+			dir = "."
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		err = simulation.WriteCSV(dir, res)
+	case "json":
+		if *out == "" {
+			err = simulation.WriteJSON(os.Stdout, res)
+			break
+		}
+		var f *os.File
+		f, err = os.Create(*out)
+		if err != nil {
+			return err
+		}
+		err = simulation.WriteJSON(f, res)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	default:
+		return errors.New("unknown format " + *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// log writes to stderr, stdout is kept for the json document
+	log.Printf("simulated %d citizens over %s in %s: %d shortages", c.Citizens, time.Duration(c.Duration), time.Since(started).Round(time.Millisecond), len(res.Shortages))
+	return nil
+}
@@ -0,0 +1,158 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// Duration is a time.Duration written as a string in configuration files,
+// e.g. "10m" or "24h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Profile is the behaviour of a group of synthetic citizens.
+type Profile struct {
+	Name string `json:"name"`
+	// Share is the weight of the profile among the citizens.
+	Share float64 `json:"share"`
+	// DemandsPerHour and SuppliesPerHour are the mean number of demands
+	// created and supplies sent by a citizen per hour.
+	DemandsPerHour  float64 `json:"demandsPerHour"`
+	SuppliesPerHour float64 `json:"suppliesPerHour"`
+	// MinQuantity and MaxQuantity bound the quantity of a demand.
+	MinQuantity float64 `json:"minQuantity"`
+	MaxQuantity float64 `json:"maxQuantity"`
+	// SupplyCapacity is the most supplied at once, 0 supplies what remains.
+	SupplyCapacity float64 `json:"supplyCapacity"`
+	// Categories weighs the categories demanded and supplied, the categories
+	// of the configuration when empty.
+	Categories map[string]float64 `json:"categories,omitempty"`
+}
+
+// Config describes a simulation run.
+type Config struct {
+	Citizens int       `json:"citizens"`
+	Start    time.Time `json:"start"`
+	Duration Duration  `json:"duration"`
+	// Step is the simulated time between two steps, the resolution of the
+	// ratio series.
+	Step Duration `json:"step"`
+	Seed int64    `json:"seed"`
+	// Categories weighs the categories of every profile without its own.
+	Categories map[string]float64 `json:"categories"`
+	Profiles   []Profile          `json:"profiles"`
+	// Scoring is the strategy of the final rankings, classic when empty, and
+	// HalfLife the half-life of the decayed strategy.
+	Scoring  string   `json:"scoring,omitempty"`
+	HalfLife Duration `json:"halfLife,omitempty"`
+}
+
+func (c Config) scoring() economy.Scoring {
+	return economy.Scoring{Strategy: c.Scoring, HalfLife: time.Duration(c.HalfLife)}
+}
+
+// DefaultConfig returns a day of 50 citizens split between consumers,
+// producers and balanced citizens.
+func DefaultConfig() Config {
+	return Config{
+		Citizens: 50,
+		Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Duration: Duration(24 * time.Hour),
+		Step:     Duration(10 * time.Minute),
+		Seed:     1,
+		Categories: map[string]float64{
+			"water":   3,
+			"food":    3,
+			"housing": 1,
+			"other":   1,
+		},
+		Profiles: []Profile{
+			{Name: "consumer", Share: 0.5, DemandsPerHour: 0.5, SuppliesPerHour: 0.1, MinQuantity: 1, MaxQuantity: 10, SupplyCapacity: 2},
+			{Name: "producer", Share: 0.2, DemandsPerHour: 0.1, SuppliesPerHour: 1, MinQuantity: 1, MaxQuantity: 5, SupplyCapacity: 20},
+			{Name: "balanced", Share: 0.3, DemandsPerHour: 0.3, SuppliesPerHour: 0.3, MinQuantity: 1, MaxQuantity: 10, SupplyCapacity: 5},
+		},
+	}
+}
+
+// LoadConfig reads a JSON configuration file. Omitted settings keep the value
+// of DefaultConfig.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c := Config{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Config{}, err
+	}
+
+	def := DefaultConfig()
+	if c.Citizens == 0 {
+		c.Citizens = def.Citizens
+	}
+	if c.Start.IsZero() {
+		c.Start = def.Start
+	}
+	if c.Duration == 0 {
+		c.Duration = def.Duration
+	}
+	if c.Step == 0 {
+		c.Step = def.Step
+	}
+	if c.Seed == 0 {
+		c.Seed = def.Seed
+	}
+	if len(c.Categories) == 0 {
+		c.Categories = def.Categories
+	}
+	if len(c.Profiles) == 0 {
+		c.Profiles = def.Profiles
+	}
+	return c, c.Validate()
+}
+
+// Validate checks the configuration can be run.
+func (c Config) Validate() error {
+	switch {
+	case c.Citizens <= 0:
+		return errors.New("simulation: citizens must be positive")
+	case c.Duration <= 0:
+		return errors.New("simulation: duration must be positive")
+	case c.Step <= 0:
+		return errors.New("simulation: step must be positive")
+	case len(c.Profiles) == 0:
+		return errors.New("simulation: no profile")
+	}
+	if err := c.scoring().Validate(); err != nil {
+		return errors.New("simulation: " + err.Error())
+	}
+	for _, p := range c.Profiles {
+		if p.Share < 0 || p.DemandsPerHour < 0 || p.SuppliesPerHour < 0 {
+			return errors.New("simulation: negative rate in profile " + p.Name)
+		}
+		if p.MaxQuantity < p.MinQuantity {
+			return errors.New("simulation: maxQuantity below minQuantity in profile " + p.Name)
+		}
+	}
+	return nil
+}
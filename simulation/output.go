@@ -0,0 +1,74 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// WriteJSON writes the result as a single JSON document.
+func WriteJSON(w io.Writer, res *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// WriteCSV writes the result to ratios.csv, shortages.csv and rankings.csv in
+// dir.
+func WriteCSV(dir string, res *Result) error {
+	ratios := [][]string{{"time", "category", "demands", "supplies", "ratio"}}
+	for _, r := range res.Ratios {
+		ratios = append(ratios, []string{
+			r.Time.Format(time.RFC3339),
+			r.Category,
+			strconv.Itoa(r.Demands),
+			strconv.Itoa(r.Supplies),
+			formatFloat(r.Ratio),
+		})
+	}
+
+	shortages := [][]string{{"category", "start", "end"}}
+	for _, s := range res.Shortages {
+		shortages = append(shortages, []string{s.Category, s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339)})
+	}
+
+	rankings := [][]string{{"rank", "citizen", "demand_ratio", "supply_ratio", "reputation_index"}}
+	for i, r := range res.Rankings {
+		rankings = append(rankings, []string{
+			strconv.Itoa(i + 1),
+			r.CitizenID,
+			formatFloat(r.DemandRatio),
+			formatFloat(r.SupplyRatio),
+			formatFloat(r.ReputationIndex),
+		})
+	}
+
+	if err := writeCSVFile(filepath.Join(dir, "ratios.csv"), ratios); err != nil {
+		return err
+	}
+	if err := writeCSVFile(filepath.Join(dir, "shortages.csv"), shortages); err != nil {
+		return err
+	}
+	return writeCSVFile(filepath.Join(dir, "rankings.csv"), rankings)
+}
+
+func writeCSVFile(path string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
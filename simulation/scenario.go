@@ -0,0 +1,233 @@
+package simulation
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// Arrival distributions of the demands of a Scenario.
+const (
+	// ArrivalUniform spreads Count demands uniformly over the span.
+	ArrivalUniform = "uniform"
+	// ArrivalPoisson creates demands as a Poisson process of RatePerHour.
+	ArrivalPoisson = "poisson"
+)
+
+// Scenario declares a dataset of demand records. Generating a scenario twice
+// with the same seed and end gives the same records.
+type Scenario struct {
+	Seed     int64 `json:"seed"`
+	Citizens int   `json:"citizens"`
+	// Span is the period covered by the records, ending at End or at the
+	// time of generation when End is zero.
+	Span       Duration      `json:"span"`
+	End        time.Time     `json:"end"`
+	Categories []CategoryMix `json:"categories"`
+	Arrival    Arrival       `json:"arrival"`
+	// FulfilmentProbability is the chance of a demand to be fully supplied
+	// and PartialProbability the chance of any other to be partially
+	// supplied.
+	FulfilmentProbability float64 `json:"fulfilmentProbability"`
+	PartialProbability    float64 `json:"partialProbability"`
+	// SupplyDelay is the longest time between a demand and its supply.
+	SupplyDelay Duration `json:"supplyDelay"`
+}
+
+// CategoryMix is the share of a category among the demands of a Scenario.
+type CategoryMix struct {
+	Name        string  `json:"name"`
+	Weight      float64 `json:"weight"`
+	Unit        string  `json:"unit"`
+	MinQuantity float64 `json:"minQuantity"`
+	MaxQuantity float64 `json:"maxQuantity"`
+}
+
+// Arrival is the distribution of the creation times of the demands.
+type Arrival struct {
+	Distribution string  `json:"distribution"`
+	Count        int     `json:"count"`
+	RatePerHour  float64 `json:"ratePerHour"`
+}
+
+// DefaultScenario returns a scenario of about 30 demands of 10 citizens over
+// the span, most of them supplied.
+func DefaultScenario(span time.Duration) Scenario {
+	return Scenario{
+		Seed:     1,
+		Citizens: 10,
+		Span:     Duration(span),
+		Categories: []CategoryMix{
+			{Name: "water", Weight: 3, Unit: "litres", MinQuantity: 5, MaxQuantity: 50},
+			{Name: "food", Weight: 3, Unit: "kg", MinQuantity: 1, MaxQuantity: 10},
+			{Name: "housing", Weight: 1, Unit: "person-nights", MinQuantity: 1, MaxQuantity: 7},
+			{Name: "other", Weight: 1, MinQuantity: 1, MaxQuantity: 5},
+		},
+		Arrival:               Arrival{Distribution: ArrivalUniform, Count: 30},
+		FulfilmentProbability: 0.6,
+		PartialProbability:    0.2,
+		SupplyDelay:           Duration(span / 6),
+	}
+}
+
+// LoadScenario reads a JSON scenario file.
+func LoadScenario(path string) (Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	s := Scenario{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return Scenario{}, err
+	}
+	return s, s.Validate()
+}
+
+// Validate checks the scenario can be generated.
+func (s Scenario) Validate() error {
+	switch {
+	case s.Citizens <= 0:
+		return errors.New("scenario: citizens must be positive")
+	case s.Span <= 0:
+		return errors.New("scenario: span must be positive")
+	case len(s.Categories) == 0:
+		return errors.New("scenario: no category")
+	case s.FulfilmentProbability < 0 || s.FulfilmentProbability > 1:
+		return errors.New("scenario: fulfilmentProbability must be between 0 and 1")
+	case s.PartialProbability < 0 || s.PartialProbability > 1:
+		return errors.New("scenario: partialProbability must be between 0 and 1")
+	}
+	for _, c := range s.Categories {
+		if c.Name == "" || c.Weight < 0 || c.MaxQuantity < c.MinQuantity {
+			return fmt.Errorf("scenario: invalid category %q", c.Name)
+		}
+		if _, ok := economy.LookupUnit(c.Unit); c.Unit != "" && !ok {
+			return fmt.Errorf("scenario: unknown unit %q of category %q", c.Unit, c.Name)
+		}
+	}
+	switch s.Arrival.Distribution {
+	case ArrivalUniform:
+		if s.Arrival.Count < 0 {
+			return errors.New("scenario: arrival count must not be negative")
+		}
+	case ArrivalPoisson:
+		if s.Arrival.RatePerHour < 0 {
+			return errors.New("scenario: arrival rate must not be negative")
+		}
+	default:
+		return fmt.Errorf("scenario: unknown arrival distribution %q", s.Arrival.Distribution)
+	}
+	return nil
+}
+
+// Generate returns the demand records of the scenario, oldest first. now is
+// the end of the span when the scenario has none.
+func Generate(s Scenario, now time.Time) ([]economy.Request, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	end := s.End
+	if end.IsZero() {
+		end = now
+	}
+	span := time.Duration(s.Span)
+	start := end.Add(-span)
+	rnd := rand.New(rand.NewSource(s.Seed))
+
+	times := []time.Time{}
+	switch s.Arrival.Distribution {
+	case ArrivalUniform:
+		for i := 0; i < s.Arrival.Count; i++ {
+			times = append(times, start.Add(time.Duration(rnd.Int63n(int64(span)))))
+		}
+	case ArrivalPoisson:
+		for n := poisson(rnd, s.Arrival.RatePerHour*span.Hours()); n > 0; n-- {
+			times = append(times, start.Add(time.Duration(rnd.Int63n(int64(span)))))
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	weights := make(map[string]float64, len(s.Categories))
+	mixes := make(map[string]CategoryMix, len(s.Categories))
+	for _, c := range s.Categories {
+		weights[c.Name] += c.Weight
+		mixes[c.Name] = c
+	}
+
+	// citizens are named by the handles of their keys like real ones
+	keys := make([]ed25519.PrivateKey, s.Citizens)
+	handles := make([]string, s.Citizens)
+	for i := range keys {
+		keys[i] = citizenKey(s.Seed, i)
+		handles[i] = economy.Handle(keys[i].Public().(ed25519.PublicKey))
+	}
+
+	requests := make([]economy.Request, 0, len(times))
+	for _, at := range times {
+		mix := mixes[pick(rnd, weights)]
+		q := mix.MinQuantity + rnd.Float64()*(mix.MaxQuantity-mix.MinQuantity)
+		// the entropy is drawn from the seeded source to keep IDs stable
+		var entropy [10]byte
+		rnd.Read(entropy[:])
+		requester := rnd.Intn(s.Citizens)
+		r := economy.Request{
+			ID:        economy.RequestID(at, entropy),
+			CitizenID: handles[requester],
+			Category:  mix.Name,
+			Quantity:  economy.Quantity{Amount: math.Round(q*100) / 100, Unit: mix.Unit},
+			CreatedAt: at,
+		}
+
+		supplied := 0.0
+		switch x := rnd.Float64(); {
+		case x < s.FulfilmentProbability:
+			supplied = r.Quantity.Amount
+		case x < s.FulfilmentProbability+(1-s.FulfilmentProbability)*s.PartialProbability:
+			supplied = r.Quantity.Amount * (0.1 + 0.8*rnd.Float64())
+		}
+		if supplied > 0 && s.Citizens > 1 {
+			supplier := rnd.Intn(s.Citizens - 1)
+			if supplier == requester {
+				// the requester never supplies itself
+				supplier = s.Citizens - 1
+			}
+			suppliedAt := at
+			if s.SupplyDelay > 0 {
+				suppliedAt = at.Add(time.Duration(rnd.Int63n(int64(s.SupplyDelay))))
+			}
+			if suppliedAt.After(end) {
+				suppliedAt = end
+			}
+			c := economy.Contribution{Supplier: handles[supplier], Amount: supplied, SuppliedAt: suppliedAt}
+			c.Sign(r.ID, keys[supplier])
+			r.Contribute(c)
+			r.Version++
+		}
+		if err := r.Sign(keys[requester]); err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, nil
+}
+
+func citizenID(i int) string {
+	return fmt.Sprintf("citizen-%04d", i+1)
+}
+
+// citizenKey returns the signing key of synthetic citizen i. It is derived
+// from the seed so the generated records verify and stay reproducible.
+func citizenKey(seed int64, i int) ed25519.PrivateKey {
+	h := sha256.Sum256([]byte(strconv.FormatInt(seed, 10) + "/" + citizenID(i)))
+	return ed25519.NewKeyFromSeed(h[:])
+}
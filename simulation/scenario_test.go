@@ -0,0 +1,136 @@
+package simulation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	end := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := DefaultScenario(24 * time.Hour)
+	s.End = end
+
+	a, err := Generate(s, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the generation time does not matter once the end is set
+	b, err := Generate(s, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("two datasets of the same seed and end differ")
+	}
+	if len(a) != s.Arrival.Count {
+		t.Fatalf("got %d demands, want %d", len(a), s.Arrival.Count)
+	}
+
+	s.Seed = 2
+	c, err := Generate(s, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(a, c) {
+		t.Fatal("datasets of different seeds are the same")
+	}
+
+	// without an end the span ends at now
+	s.End = time.Time{}
+	d, err := Generate(s, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Generate(s, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, e) {
+		t.Fatal("two datasets of the same seed and now differ")
+	}
+}
+
+func TestGenerateRecords(t *testing.T) {
+	end := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, arrival := range []Arrival{
+		{Distribution: ArrivalUniform, Count: 50},
+		{Distribution: ArrivalPoisson, RatePerHour: 2},
+	} {
+		s := DefaultScenario(24 * time.Hour)
+		s.End = end
+		s.Arrival = arrival
+		requests, err := Generate(s, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(requests) == 0 {
+			t.Fatalf("%s: no demand", arrival.Distribution)
+		}
+
+		k := economy.NewKeyring()
+		start := end.Add(-24 * time.Hour)
+		for i, r := range requests {
+			if err := k.Verify(r); err != nil {
+				t.Fatalf("%s: demand %s: %v", arrival.Distribution, r.ID, err)
+			}
+			if r.CreatedAt.Before(start) || r.CreatedAt.After(end) {
+				t.Fatalf("%s: demand %s created at %v out of the span", arrival.Distribution, r.ID, r.CreatedAt)
+			}
+			if i > 0 && r.CreatedAt.Before(requests[i-1].CreatedAt) {
+				t.Fatalf("%s: demands not sorted by creation time", arrival.Distribution)
+			}
+			for _, c := range r.Contributions {
+				if c.Supplier == r.CitizenID || c.SuppliedAt.After(end) {
+					t.Fatalf("%s: demand %s has the contribution %+v", arrival.Distribution, r.ID, c)
+				}
+			}
+			if err := r.Quantity.Validate(); err != nil {
+				t.Fatalf("%s: demand %s: %v", arrival.Distribution, r.ID, err)
+			}
+		}
+	}
+}
+
+func TestScenarioValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *Scenario)
+		err    string
+	}{
+		{"valid", func(s *Scenario) {}, ""},
+		{"no citizen", func(s *Scenario) { s.Citizens = 0 }, "citizens"},
+		{"no span", func(s *Scenario) { s.Span = 0 }, "span"},
+		{"no category", func(s *Scenario) { s.Categories = nil }, "no category"},
+		{"fulfilment above 1", func(s *Scenario) { s.FulfilmentProbability = 1.5 }, "fulfilmentProbability"},
+		{"negative partial", func(s *Scenario) { s.PartialProbability = -0.1 }, "partialProbability"},
+		{"unnamed category", func(s *Scenario) { s.Categories[0].Name = "" }, "invalid category"},
+		{"quantities reversed", func(s *Scenario) { s.Categories[0].MaxQuantity = 1 }, "invalid category"},
+		{"unknown unit", func(s *Scenario) { s.Categories[0].Unit = "buckets" }, "unknown unit"},
+		{"negative count", func(s *Scenario) { s.Arrival.Count = -1 }, "count"},
+		{"negative rate", func(s *Scenario) { s.Arrival = Arrival{Distribution: ArrivalPoisson, RatePerHour: -1} }, "rate"},
+		{"unknown arrival", func(s *Scenario) { s.Arrival.Distribution = "bursty" }, "distribution"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := DefaultScenario(time.Hour)
+			tt.change(&s)
+			err := s.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %v, want an error about %s", err, tt.err)
+			}
+			if _, err := Generate(s, time.Now()); err == nil {
+				t.Fatal("generated an invalid scenario")
+			}
+		})
+	}
+}
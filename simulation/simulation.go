@@ -0,0 +1,283 @@
+// Package simulation runs synthetic citizens against the economy model
+// without a browser, to study how ratios, shortages and rankings evolve, and
+// generates reproducible datasets of demand records from scenarios.
+package simulation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// Result is the outcome of a run.
+type Result struct {
+	Ratios    []RatioPoint      `json:"ratios"`
+	Shortages []ShortageEvent   `json:"shortages"`
+	Rankings  []economy.Ranking `json:"rankings"`
+}
+
+// RatioPoint is the state of a category at the end of a step. The category
+// economy.CategoryAll covers every demand.
+type RatioPoint struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	// Demands and Supplies are the demands created and the supplies sent
+	// during the step.
+	Demands  int `json:"demands"`
+	Supplies int `json:"supplies"`
+	// Ratio is the supply/demand ratio of the demands created during the
+	// step, 1 without any.
+	Ratio float64 `json:"ratio"`
+}
+
+// ShortageEvent is a period a category was short of supplies, see
+// economy.Shortages. End is the end of the run for shortages still ongoing.
+type ShortageEvent struct {
+	Category string    `json:"category"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+type citizen struct {
+	id         string
+	profile    Profile
+	categories map[string]float64
+}
+
+// Run simulates the configured period. Runs with the same configuration give
+// the same result.
+func Run(c Config) (*Result, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	rnd := rand.New(rand.NewSource(c.Seed))
+	citizens := newCitizens(c, rnd)
+	categories := categoriesOf(c)
+	m := economy.NewLedger()
+	step := time.Duration(c.Step)
+	end := c.Start.Add(time.Duration(c.Duration))
+	res := &Result{Ratios: []RatioPoint{}}
+	shortages := newShortageTracker(end)
+	seq := 0
+
+	for t := c.Start; t.Before(end); t = t.Add(step) {
+		next := t.Add(step)
+		demands := make(map[string]int)
+		supplies := make(map[string]int)
+
+		for _, ct := range citizens {
+			for n := poisson(rnd, ct.profile.DemandsPerHour*step.Hours()); n > 0; n-- {
+				at := t.Add(time.Duration(rnd.Int63n(int64(step))))
+				q := ct.profile.MinQuantity + rnd.Float64()*(ct.profile.MaxQuantity-ct.profile.MinQuantity)
+				seq++
+				r := economy.Request{
+					ID:        requestID(at, seq),
+					CitizenID: ct.id,
+					Category:  pick(rnd, ct.categories),
+					Quantity:  economy.Quantity{Amount: math.Round(q*100) / 100},
+					CreatedAt: at,
+				}
+				m.Apply(r)
+				demands[r.Category]++
+			}
+		}
+
+		// suppliers take turns in a random order so none is always first
+		for _, i := range rnd.Perm(len(citizens)) {
+			ct := citizens[i]
+			for n := poisson(rnd, ct.profile.SuppliesPerHour*step.Hours()); n > 0; n-- {
+				r, ok := oldestPending(m, ct, next)
+				if !ok {
+					break
+				}
+				amount := r.Remaining()
+				if ct.profile.SupplyCapacity > 0 && amount > ct.profile.SupplyCapacity {
+					amount = ct.profile.SupplyCapacity
+				}
+				r.Contribute(economy.Contribution{Supplier: ct.id, Amount: amount, SuppliedAt: next})
+				r.Version++
+				m.Apply(r)
+				supplies[r.Category]++
+			}
+		}
+
+		res.Ratios = append(res.Ratios, ratioPoints(m, categories, t, next, demands, supplies)...)
+
+		shortages.update(economy.Shortages(m.Requests(), next), next)
+	}
+
+	res.Shortages = shortages.events
+	res.Rankings = c.scoring().Rank(m.Requests(), end)
+	return res, nil
+}
+
+// shortageTracker turns the categories short of supplies at every step into
+// shortage events.
+type shortageTracker struct {
+	events []ShortageEvent
+	// ongoing holds the index in events of the shortage of a category
+	ongoing map[string]int
+	end     time.Time
+}
+
+func newShortageTracker(end time.Time) *shortageTracker {
+	return &shortageTracker{events: []ShortageEvent{}, ongoing: make(map[string]int), end: end}
+}
+
+// update starts the shortages of the categories short at t and ends the
+// shortages of the other ones. Shortages still ongoing end with the run.
+func (s *shortageTracker) update(short []string, at time.Time) {
+	isShort := make(map[string]bool, len(short))
+	for _, cat := range short {
+		isShort[cat] = true
+		if _, ok := s.ongoing[cat]; !ok {
+			s.ongoing[cat] = len(s.events)
+			s.events = append(s.events, ShortageEvent{Category: cat, Start: at, End: s.end})
+		}
+	}
+	for cat, i := range s.ongoing {
+		if !isShort[cat] {
+			s.events[i].End = at
+			delete(s.ongoing, cat)
+		}
+	}
+}
+
+func newCitizens(c Config, rnd *rand.Rand) []citizen {
+	shares := make(map[string]float64, len(c.Profiles))
+	profiles := make(map[string]Profile, len(c.Profiles))
+	for i, p := range c.Profiles {
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("profile-%d", i+1)
+		}
+		shares[p.Name] += p.Share
+		profiles[p.Name] = p
+	}
+
+	citizens := make([]citizen, 0, c.Citizens)
+	for i := 0; i < c.Citizens; i++ {
+		p := profiles[pick(rnd, shares)]
+		cats := p.Categories
+		if len(cats) == 0 {
+			cats = c.Categories
+		}
+		citizens = append(citizens, citizen{
+			id:         citizenID(i),
+			profile:    p,
+			categories: cats,
+		})
+	}
+	return citizens
+}
+
+// categoriesOf returns every category of the configuration, sorted.
+func categoriesOf(c Config) []string {
+	seen := make(map[string]bool)
+	for cat := range c.Categories {
+		seen[cat] = true
+	}
+	for _, p := range c.Profiles {
+		for cat := range p.Categories {
+			seen[cat] = true
+		}
+	}
+	res := make([]string, 0, len(seen))
+	for cat := range seen {
+		res = append(res, cat)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// oldestPending returns the oldest demand of another citizen, created before
+// now, the citizen can supply.
+func oldestPending(m *economy.Ledger, ct citizen, now time.Time) (economy.Request, bool) {
+	for _, id := range m.IDs() {
+		r := m.Request(id)
+		if r.CreatedAt.After(now) {
+			break
+		}
+		if r.Fulfilled || r.CitizenID == ct.id || ct.categories[r.Category] <= 0 {
+			continue
+		}
+		return r, true
+	}
+	return economy.Request{}, false
+}
+
+func ratioPoints(m *economy.Ledger, categories []string, from, to time.Time, demands, supplies map[string]int) []RatioPoint {
+	created := make(map[string][]economy.Request)
+	for _, r := range m.Requests() {
+		if r.CreatedAt.Before(from) || !r.CreatedAt.Before(to) {
+			continue
+		}
+		created[r.Category] = append(created[r.Category], r)
+		created[economy.CategoryAll] = append(created[economy.CategoryAll], r)
+	}
+
+	all := RatioPoint{Time: to, Category: economy.CategoryAll, Ratio: economy.Ratio(created[economy.CategoryAll])}
+	res := []RatioPoint{}
+	for _, cat := range categories {
+		res = append(res, RatioPoint{
+			Time:     to,
+			Category: cat,
+			Demands:  demands[cat],
+			Supplies: supplies[cat],
+			Ratio:    economy.Ratio(created[cat]),
+		})
+		all.Demands += demands[cat]
+		all.Supplies += supplies[cat]
+	}
+	return append(res, all)
+}
+
+// requestID returns a request ID whose entropy is derived from the sequence so
+// runs are reproducible.
+func requestID(t time.Time, seq int) string {
+	var entropy [10]byte
+	binary.BigEndian.PutUint64(entropy[2:], uint64(seq))
+	return economy.RequestID(t, entropy)
+}
+
+// pick returns a key of weights at random in proportion to its weight.
+func pick(rnd *rand.Rand, weights map[string]float64) string {
+	keys := make([]string, 0, len(weights))
+	var total float64
+	for k, w := range weights {
+		if w > 0 {
+			keys = append(keys, k)
+			total += w
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	// map order is random, the draw must not be
+	sort.Strings(keys)
+	x := rnd.Float64() * total
+	for _, k := range keys {
+		x -= weights[k]
+		if x < 0 {
+			return k
+		}
+	}
+	return keys[len(keys)-1]
+}
+
+// poisson draws the number of events of a Poisson process of the given mean.
+func poisson(rnd *rand.Rand, mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+	l := math.Exp(-mean)
+	n := 0
+	for p := rnd.Float64(); p > l; p *= rnd.Float64() {
+		n++
+	}
+	return n
+}
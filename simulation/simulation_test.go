@@ -0,0 +1,222 @@
+package simulation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+func smallConfig() Config {
+	c := DefaultConfig()
+	c.Citizens = 10
+	c.Duration = Duration(6 * time.Hour)
+	return c
+}
+
+func TestRunReproducible(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func() Config
+	}{
+		{"default", smallConfig},
+		{"decayed", func() Config {
+			c := smallConfig()
+			c.Scoring = economy.ScoringDecayed
+			c.HalfLife = Duration(time.Hour)
+			return c
+		}},
+		{"scarcity", func() Config {
+			c := smallConfig()
+			c.Seed = 42
+			c.Scoring = economy.ScoringScarcity
+			return c
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Run(tt.config())
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Run(tt.config())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(a, b) {
+				t.Fatal("two runs of the same configuration differ")
+			}
+			if len(a.Rankings) == 0 || len(a.Ratios) == 0 {
+				t.Fatalf("got %d rankings and %d ratios", len(a.Rankings), len(a.Ratios))
+			}
+		})
+	}
+
+	c := smallConfig()
+	c.Seed = 2
+	a, err := Run(smallConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Run(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(a.Ratios, b.Ratios) {
+		t.Fatal("runs of different seeds are the same")
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	c := smallConfig()
+	c.Step = 0
+	if _, err := Run(c); err == nil {
+		t.Fatal("run without step")
+	}
+}
+
+func TestShortageTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(5 * time.Hour)
+	at := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+	tests := []struct {
+		name  string
+		steps [][]string
+		want  []ShortageEvent
+	}{
+		{"none", [][]string{{}, {}}, []ShortageEvent{}},
+		{"ongoing until the end", [][]string{{}, {"water"}, {"water"}}, []ShortageEvent{
+			{Category: "water", Start: at(1), End: end},
+		}},
+		{"ended", [][]string{{"water"}, {"water"}, {}}, []ShortageEvent{
+			{Category: "water", Start: at(0), End: at(2)},
+		}},
+		{"twice", [][]string{{"water"}, {}, {"water"}}, []ShortageEvent{
+			{Category: "water", Start: at(0), End: at(1)},
+			{Category: "water", Start: at(2), End: end},
+		}},
+		{"overlapping", [][]string{{"water"}, {"water", "food"}, {"food"}, {}}, []ShortageEvent{
+			{Category: "water", Start: at(0), End: at(2)},
+			{Category: "food", Start: at(1), End: at(3)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShortageTracker(end)
+			for h, short := range tt.steps {
+				s.update(short, at(h))
+			}
+			if !reflect.DeepEqual(s.events, tt.want) {
+				t.Fatalf("got %+v, want %+v", s.events, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunShortages(t *testing.T) {
+	// citizens who never supply
+	c := smallConfig()
+	for i := range c.Profiles {
+		c.Profiles[i].SuppliesPerHour = 0
+	}
+	res, err := Run(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Shortages) == 0 {
+		t.Fatal("no shortage without supplies")
+	}
+	end := c.Start.Add(time.Duration(c.Duration))
+	last := make(map[string]time.Time)
+	for _, s := range res.Shortages {
+		if s.Start.Before(c.Start) || s.End.After(end) || s.End.Before(s.Start) {
+			t.Fatalf("shortage %+v out of the run", s)
+		}
+		if s.Start.Before(last[s.Category]) {
+			t.Fatalf("shortages of %s overlap", s.Category)
+		}
+		last[s.Category] = s.End
+	}
+}
+
+func testResult() *Result {
+	at := time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC)
+	return &Result{
+		Ratios: []RatioPoint{
+			{Time: at, Category: "water", Demands: 2, Supplies: 1, Ratio: 0.5},
+			{Time: at, Category: economy.CategoryAll, Demands: 2, Supplies: 1, Ratio: 0.5},
+		},
+		Shortages: []ShortageEvent{{Category: "water", Start: at, End: at.Add(time.Hour)}},
+		Rankings: []economy.Ranking{
+			{CitizenID: "citizen-0002", DemandRatio: 0, SupplyRatio: 1, ReputationIndex: 1},
+			{CitizenID: "citizen-0001", DemandRatio: 1, SupplyRatio: 0, ReputationIndex: -1},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	res := testResult()
+	var b bytes.Buffer
+	if err := WriteJSON(&b, res); err != nil {
+		t.Fatal(err)
+	}
+	got := &Result{}
+	if err := json.Unmarshal(b.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, res) {
+		t.Fatalf("got %+v, want %+v", got, res)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteCSV(dir, testResult()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file string
+		want [][]string
+	}{
+		{"ratios.csv", [][]string{
+			{"time", "category", "demands", "supplies", "ratio"},
+			{"2024-01-01T00:10:00Z", "water", "2", "1", "0.500000"},
+			{"2024-01-01T00:10:00Z", economy.CategoryAll, "2", "1", "0.500000"},
+		}},
+		{"shortages.csv", [][]string{
+			{"category", "start", "end"},
+			{"water", "2024-01-01T00:10:00Z", "2024-01-01T01:10:00Z"},
+		}},
+		{"rankings.csv", [][]string{
+			{"rank", "citizen", "demand_ratio", "supply_ratio", "reputation_index"},
+			{"1", "citizen-0002", "0.000000", "1.000000", "1.000000"},
+			{"2", "citizen-0001", "1.000000", "0.000000", "-1.000000"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := csv.NewReader(f).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := WriteCSV(filepath.Join(dir, "missing"), testResult()); err == nil {
+		t.Fatal("wrote to a missing directory")
+	}
+}
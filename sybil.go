@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// dbNameCitizenVouches holds the vouches of the citizens under their
+// economy.Vouch key.
+const dbNameCitizenVouches = "citizen_vouches"
+
+// messageVouch carries an economy.Vouch on the demand topic.
+const messageVouch = "vouch"
+
+// rankingModeKey is where the choice of discounted rankings is kept in the
+// browser local storage.
+const rankingModeKey = "discountedRanks"
+
+// trustDepth is how many vouches away from the citizen an identity is still
+// trusted.
+const trustDepth = 3
+
+// trusted returns the identities in the web of trust of the citizen.
+func (p *pubsub) trusted() map[string]bool {
+	vouches := make([]economy.Vouch, 0, len(p.vouches))
+	for _, v := range p.vouches {
+		vouches = append(vouches, v)
+	}
+	return economy.Trusted(vouches, p.keys, []string{p.citizenID}, trustDepth)
+}
+
+// vouched reports whether the citizen vouched for another one.
+func (p *pubsub) vouched(citizenID string) bool {
+	for _, v := range p.vouches {
+		if p.mine(v.Voucher) && p.keys.Latest(v.Citizen) == citizenID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pubsub) loadRankingMode(ctx app.Context) {
+	if err := ctx.LocalStorage().Get(rankingModeKey, &p.discounted); err != nil {
+		log.Println(err)
+	}
+}
+
+func (p *pubsub) onRankingMode(ctx app.Context, e app.Event) {
+	p.discounted = ctx.JSSrc().Get("checked").Bool()
+	if err := ctx.LocalStorage().Set(rankingModeKey, p.discounted); err != nil {
+		log.Println(err)
+	}
+	p.ranks = p.rank()
+}
+
+// vouchFor vouches that the citizen of the clicked rank is a distinct person.
+func (p *pubsub) vouchFor(ctx app.Context, e app.Event) {
+	citizenID := ctx.JSSrc().Get("value").String()
+	if citizenID == "" || p.mine(citizenID) {
+		return
+	}
+	v := economy.NewVouch(p.key, citizenID, time.Now())
+	p.vouches[v.Key()] = v
+	p.ranks = p.rank()
+
+	ctx.Async(func() {
+		vouch, err := json.Marshal(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// store in orbit-db first
+		err = p.ledger.Put(dbNameCitizenVouches, v.Key(), vouch)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = publishMessage(p.transport, p.topic, messageVouch, v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+}
+
+func (p *pubsub) FetchAllVouches(ctx app.Context) {
+	ctx.Async(func() {
+		vs, err := p.ledger.List(dbNameCitizenVouches)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		vouches := make([]economy.Vouch, 0, len(vs))
+		malformed := []error{}
+		for key, b := range vs {
+			v := economy.Vouch{}
+			err = json.Unmarshal(b, &v)
+			if err != nil {
+				malformed = append(malformed, malformedRecord(dbNameCitizenVouches, key, err))
+				continue
+			}
+			vouches = append(vouches, v)
+		}
+
+		ctx.Dispatch(func(ctx app.Context) {
+			for _, err := range malformed {
+				p.rejected.add(err)
+			}
+			for _, v := range vouches {
+				if err := v.Verify(p.keys); err != nil {
+					p.rejected.add(verified(err))
+					continue
+				}
+				p.vouches[v.Key()] = v
+			}
+			p.ranks = p.rank()
+		})
+	})
+}
+
+// limiter holds the times of the demands received from every identity within
+// the rate window of the policy.
+type limiter map[string][]time.Time
+
+// allow records a demand of the citizen unless it is over the rate limit.
+func (l limiter) allow(citizenID string, at time.Time, policy economy.SybilPolicy) bool {
+	times := l[citizenID]
+	for len(times) > 0 && !times[0].After(at.Add(-policy.RateWindow)) {
+		times = times[1:]
+	}
+	if len(times) >= policy.RateLimit {
+		l[citizenID] = times
+		return false
+	}
+	l[citizenID] = append(times, at)
+	return true
+}
+
+// overLimit reports whether the citizen created as many demands as allowed in
+// the rate window.
+func (p *pubsub) overLimit(now time.Time) bool {
+	n := 0
+	for _, r := range p.market.Requests() {
+		if p.mine(r.CitizenID) && r.CreatedAt.After(now.Add(-p.sybil.RateWindow)) {
+			n++
+		}
+	}
+	return n >= p.sybil.RateLimit
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	shell "github.com/stateless-minds/go-ipfs-api"
+)
+
+const (
+	TransportIPFS  = "ipfs"
+	TransportLocal = "local"
+	TransportRelay = "relay"
+)
+
+// ErrSubscriptionCancelled is returned by Subscription.Next once the
+// subscription has been cancelled.
+var ErrSubscriptionCancelled = errors.New("transport: subscription cancelled")
+
+// Message is a message received on a topic.
+type Message struct {
+	From string
+	Data []byte
+}
+
+// Transport carries the demand, supply and shortage messages between peers.
+type Transport interface {
+	// ID returns the identifier of the local peer.
+	ID() (string, error)
+	Publish(topic string, data []byte) error
+	Subscribe(topic string) (Subscription, error)
+}
+
+// Subscription delivers the messages published on a topic, including the ones
+// published by the local peer.
+type Subscription interface {
+	// Next blocks until the next message is received.
+	Next() (*Message, error)
+	Cancel() error
+}
+
+// openTransport returns the transport adapter of the given kind. The ipfs
+// adapter talks to the IPFS API at api and the relay adapter connects to the
+// WebSocket relay served by main at relayURL.
+func openTransport(kind, api, relayURL string) (Transport, error) {
+	switch kind {
+	case "", TransportIPFS:
+		return &ipfsTransport{sh: shell.NewShell(api)}, nil
+	case TransportLocal:
+		return defaultHub.Transport(newPeerID()), nil
+	case TransportRelay:
+		return newRelayTransport(relayURL)
+	}
+	return nil, fmt.Errorf("transport: unsupported backend %q", kind)
+}
+
+// newPeerID returns a random identifier for peers that are not backed by an
+// IPFS node.
+func newPeerID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ipfsTransport publishes on the IPFS pubsub through the HTTP API of the local
+// node.
+type ipfsTransport struct {
+	sh *shell.Shell
+}
+
+func (t *ipfsTransport) ID() (string, error) {
+	myPeer, err := t.sh.ID()
+	if err != nil {
+		return "", err
+	}
+	return myPeer.ID, nil
+}
+
+func (t *ipfsTransport) Publish(topic string, data []byte) error {
+	return t.sh.PubSubPublish(topic, string(data))
+}
+
+func (t *ipfsTransport) Subscribe(topic string) (Subscription, error) {
+	sub, err := t.sh.PubSubSubscribe(topic)
+	if err != nil {
+		return nil, err
+	}
+	return &ipfsSubscription{sub: sub}, nil
+}
+
+type ipfsSubscription struct {
+	sub *shell.PubSubSubscription
+}
+
+func (s *ipfsSubscription) Next() (*Message, error) {
+	res, err := s.sub.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &Message{From: res.From.String(), Data: res.Data}, nil
+}
+
+func (s *ipfsSubscription) Cancel() error {
+	return s.sub.Cancel()
+}
+
+// queueSubscription is an unbounded, in-memory subscription shared by the
+// local and relay transports.
+type queueSubscription struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	msgs     []*Message
+	canceled bool
+	onCancel func()
+}
+
+func newQueueSubscription(onCancel func()) *queueSubscription {
+	s := &queueSubscription{onCancel: onCancel}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *queueSubscription) push(m *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canceled {
+		return
+	}
+	s.msgs = append(s.msgs, m)
+	s.cond.Signal()
+}
+
+func (s *queueSubscription) Next() (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.msgs) == 0 && !s.canceled {
+		s.cond.Wait()
+	}
+	if s.canceled {
+		return nil, ErrSubscriptionCancelled
+	}
+	m := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return m, nil
+}
+
+func (s *queueSubscription) Cancel() error {
+	s.mu.Lock()
+	if s.canceled {
+		s.mu.Unlock()
+		return nil
+	}
+	s.canceled = true
+	s.msgs = nil
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	if s.onCancel != nil {
+		s.onCancel()
+	}
+	return nil
+}
+
+// topicSubscribers keeps the subscriptions of each topic.
+type topicSubscribers struct {
+	mu   sync.Mutex
+	subs map[string][]*queueSubscription
+}
+
+func (ts *topicSubscribers) add(topic string) *queueSubscription {
+	var sub *queueSubscription
+	sub = newQueueSubscription(func() {
+		ts.remove(topic, sub)
+	})
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.subs == nil {
+		ts.subs = make(map[string][]*queueSubscription)
+	}
+	ts.subs[topic] = append(ts.subs[topic], sub)
+	return sub
+}
+
+func (ts *topicSubscribers) remove(topic string, sub *queueSubscription) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	subs := ts.subs[topic]
+	for i, s := range subs {
+		if s == sub {
+			ts.subs[topic] = append(subs[:i:i], subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (ts *topicSubscribers) deliver(topic string, m *Message) {
+	ts.mu.Lock()
+	subs := append([]*queueSubscription(nil), ts.subs[topic]...)
+	ts.mu.Unlock()
+	for _, s := range subs {
+		s.push(m)
+	}
+}
+
+// defaultHub connects every local transport of the process.
+var defaultHub = newHub()
+
+// hub is an in-process message bus. Every transport obtained from the same
+// hub receives the messages published by the others, which lets several
+// citizens play in a single process without any network.
+type hub struct {
+	topicSubscribers
+}
+
+func newHub() *hub {
+	return &hub{}
+}
+
+// Transport returns a transport attached to the hub for the given peer.
+func (h *hub) Transport(peerID string) Transport {
+	return &localTransport{hub: h, id: peerID}
+}
+
+type localTransport struct {
+	hub *hub
+	id  string
+}
+
+func (t *localTransport) ID() (string, error) {
+	return t.id, nil
+}
+
+func (t *localTransport) Publish(topic string, data []byte) error {
+	t.hub.deliver(topic, &Message{From: t.id, Data: append([]byte(nil), data...)})
+	return nil
+}
+
+func (t *localTransport) Subscribe(topic string) (Subscription, error) {
+	return t.hub.add(topic), nil
+}
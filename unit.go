@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+	"github.com/stateless-minds/cyber-stasis/economy"
+)
+
+// unitListID is the datalist suggesting the known units to the unit inputs.
+const unitListID = "units"
+
+func unitList() app.UI {
+	return app.DataList().ID(unitListID).Body(
+		app.Range(economy.Units()).Slice(func(i int) app.UI {
+			return app.Option().Value(economy.Units()[i].Symbol)
+		}),
+	)
+}
+
+// unitHint explains to the citizen why a quantity was refused.
+func unitHint(err error) string {
+	if errors.Is(err, economy.ErrUnknownUnit) {
+		symbols := []string{}
+		for _, u := range economy.Units() {
+			symbols = append(symbols, u.Symbol)
+		}
+		return "Use one of the known units: " + strings.Join(symbols, ", ") + "."
+	}
+	return "Enter a positive quantity."
+}
+
+// totalTitle sums up the demand and supply of a category and its
+// subcategories.
+func (p *pubsub) totalTitle(category string) string {
+	t := p.market.Total(category)
+	if t.Demanded == 0 {
+		return ""
+	}
+	demanded := economy.Quantity{Amount: math.Round(t.Demanded*100) / 100, Unit: t.Unit}
+	supplied := economy.Quantity{Amount: math.Round(t.Supplied*100) / 100, Unit: t.Unit}
+	return demanded.String() + " demanded, " + supplied.String() + " supplied"
+}